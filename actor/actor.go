@@ -0,0 +1,199 @@
+package actor
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// an actor generalises lexical confinement (see confinement.go): its state is only ever available from one goroutine,
+// so - just like the results channel of chanOwner - nobody else can touch it, and no synchronization is needed.
+//
+// other goroutines interact with the state by sending messages to the actor's mailbox. The actor processes them
+// one after another, so within a message, you can write plain synchronous code against the state.
+// Messages are functions of the state, which keeps actors generic: any operation on the state is a message.
+
+// ErrStopped is returned for messages sent to an actor that has stopped
+var ErrStopped = errors.New("actor: stopped")
+
+// ErrMailboxFull is returned by TrySend if the mailbox is full
+var ErrMailboxFull = errors.New("actor: mailbox full")
+
+// Actor confines a state of type S to a single goroutine
+type Actor[S any] struct {
+	state   S
+	mailbox chan func(*S)
+	stopped chan any
+}
+
+// Spawn starts an actor that owns state and stops when done is closed
+//
+// the mailbox holds up to mailboxSize messages; once it's full, Send blocks, which pushes back on the senders.
+// Messages left in the mailbox when the actor stops are dropped.
+func Spawn[S any](done <-chan any, state S, mailboxSize int) *Actor[S] {
+	a := &Actor[S]{
+		state:   state,
+		mailbox: make(chan func(*S), max(mailboxSize, 0)),
+		stopped: make(chan any),
+	}
+
+	go func() {
+		defer close(a.stopped)
+		defer panic_recovery.Recover("actor", nil)
+
+		for {
+			select {
+			case <-done:
+				return
+			case msg := <-a.mailbox:
+				a.handle(msg)
+			}
+		}
+	}()
+
+	return a
+}
+
+// handle processes a single message; a panicking message is reported to the supervisor and doesn't stop the actor
+func (a *Actor[S]) handle(msg func(*S)) {
+	defer panic_recovery.Recover("actor", nil)
+	msg(&a.state)
+}
+
+// Stopped returns a channel that is closed once the actor has stopped
+func (a *Actor[S]) Stopped() <-chan any {
+	return a.stopped
+}
+
+// Send puts msg into the mailbox, blocking while the mailbox is full
+//
+// it returns ErrStopped if the actor has stopped, and done's closure is reported as ErrStopped as well,
+// since the sender is no longer interested in the actor.
+func (a *Actor[S]) Send(done <-chan any, msg func(*S)) error {
+	select {
+	case <-a.stopped:
+		return ErrStopped
+	default:
+	}
+
+	select {
+	case <-done:
+		return ErrStopped
+	case <-a.stopped:
+		return ErrStopped
+	case a.mailbox <- msg:
+		return nil
+	}
+}
+
+// TrySend puts msg into the mailbox if there is room, without blocking
+func (a *Actor[S]) TrySend(msg func(*S)) error {
+	select {
+	case <-a.stopped:
+		return ErrStopped
+	default:
+	}
+
+	select {
+	case a.mailbox <- msg:
+		return nil
+	default:
+		return ErrMailboxFull
+	}
+}
+
+// Future is the reply to a request that will be available later
+type Future[R any] struct {
+	once    sync.Once
+	ready   chan any
+	stopped <-chan any
+	value   R
+	err     error
+}
+
+func (f *Future[R]) resolve(value R, err error) {
+	f.once.Do(func() {
+		f.value, f.err = value, err
+		close(f.ready)
+	})
+}
+
+// Ready returns a channel that is closed once the reply is available
+func (f *Future[R]) Ready() <-chan any {
+	return f.ready
+}
+
+// Get blocks until the reply is available, done is closed, or the actor stopped before replying
+func (f *Future[R]) Get(done <-chan any) (R, error) {
+	var zero R
+
+	select {
+	case <-f.ready:
+		return f.value, f.err
+	case <-done:
+		return zero, ErrStopped
+	case <-f.stopped:
+		// the actor may have replied right before it stopped
+		select {
+		case <-f.ready:
+			return f.value, f.err
+		default:
+			return zero, ErrStopped
+		}
+	}
+}
+
+// Ask sends a request to the actor and returns a Future for the reply fn computes from the state
+//
+// a panic in fn resolves the future with the *panic_recovery.PanicError.
+func Ask[S, R any](done <-chan any, a *Actor[S], fn func(*S) R) *Future[R] {
+	f := &Future[R]{ready: make(chan any), stopped: a.stopped}
+
+	err := a.Send(done, func(s *S) {
+		var zero R
+		defer panic_recovery.Recover("actor.Ask", func(err *panic_recovery.PanicError) { f.resolve(zero, err) })
+
+		f.resolve(fn(s), nil)
+	})
+	if err != nil {
+		var zero R
+		f.resolve(zero, err)
+	}
+
+	return f
+}
+
+// ActorExec keeps per-connection byte counters in an actor instead of a map guarded by a mutex
+func ActorExec() {
+	done := make(chan any)
+	defer close(done)
+
+	counters := Spawn(done, map[string]int{}, 10)
+
+	var wg sync.WaitGroup
+	for _, conn := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				counters.Send(done, func(bytesIn *map[string]int) { (*bytesIn)[conn] += 10 })
+			}
+		}()
+	}
+	wg.Wait()
+
+	total, err := Ask(done, counters, func(bytesIn *map[string]int) int {
+		sum := 0
+		for _, n := range *bytesIn {
+			sum += n
+		}
+		return sum
+	}).Get(done)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+
+	fmt.Printf("received %d bytes\n", total)
+}
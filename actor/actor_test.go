@@ -0,0 +1,111 @@
+package actor_test
+
+import (
+	"concurrency-patterns/actor"
+	"concurrency-patterns/panic_recovery"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestActor_Sequential(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	// the counter is a plain int: the race detector fails the test if two messages ever touch it concurrently
+	counter := actor.Spawn(done, 0, 4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := counter.Send(done, func(n *int) { *n++ }); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := actor.Ask(done, counter, func(n *int) int { return *n }).Get(done)
+	if err != nil || got != 1000 {
+		t.Errorf("got %d, %v, want 1000", got, err)
+	}
+}
+
+func TestAsk_Panic(t *testing.T) {
+	panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+	defer panic_recovery.SetSupervisor(nil)
+
+	done := make(chan any)
+	defer close(done)
+
+	a := actor.Spawn(done, 1, 0)
+
+	var panicErr *panic_recovery.PanicError
+	if _, err := actor.Ask(done, a, func(n *int) int { panic("boom") }).Get(done); !errors.As(err, &panicErr) {
+		t.Errorf("got %v, want the recovered panic", err)
+	}
+
+	// the actor survives the panicking request
+	if got, err := actor.Ask(done, a, func(n *int) int { return *n }).Get(done); err != nil || got != 1 {
+		t.Errorf("got %d, %v after the panic, want 1", got, err)
+	}
+}
+
+func TestActor_FullMailbox(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	a := actor.Spawn(done, 0, 1)
+
+	// the actor is busy with the first message, and the second one fills the mailbox
+	busy, release := make(chan any), make(chan any)
+	a.Send(done, func(*int) {
+		close(busy)
+		<-release
+	})
+	<-busy
+	a.Send(done, func(n *int) { *n++ })
+
+	if err := a.TrySend(func(n *int) { *n++ }); !errors.Is(err, actor.ErrMailboxFull) {
+		t.Errorf("got %v, want ErrMailboxFull", err)
+	}
+
+	sent := make(chan error)
+	go func() { sent <- a.Send(done, func(n *int) { *n++ }) }()
+
+	select {
+	case err := <-sent:
+		t.Fatalf("got %v, want Send to block while the mailbox is full", err)
+	default:
+	}
+
+	close(release)
+	if err := <-sent; err != nil {
+		t.Errorf("got %v, want Send to succeed once the mailbox has room", err)
+	}
+	if got, _ := actor.Ask(done, a, func(n *int) int { return *n }).Get(done); got != 2 {
+		t.Errorf("got %d, want 2", got)
+	}
+}
+
+func TestActor_Stopped(t *testing.T) {
+	done := make(chan any)
+	a := actor.Spawn(done, 0, 1)
+
+	close(done)
+	<-a.Stopped()
+
+	if err := a.Send(make(chan any), func(*int) {}); !errors.Is(err, actor.ErrStopped) {
+		t.Errorf("got %v from Send, want ErrStopped", err)
+	}
+	if err := a.TrySend(func(*int) {}); !errors.Is(err, actor.ErrStopped) {
+		t.Errorf("got %v from TrySend, want ErrStopped", err)
+	}
+	if _, err := actor.Ask(make(chan any), a, func(n *int) int { return *n }).Get(make(chan any)); !errors.Is(err, actor.ErrStopped) {
+		t.Errorf("got %v from Ask, want ErrStopped", err)
+	}
+}
@@ -2,6 +2,7 @@ package bridge_channel
 
 import (
 	ordone "concurrency-patterns/or_done_channel"
+	"concurrency-patterns/panic_recovery"
 	"fmt"
 )
 
@@ -16,6 +17,7 @@ func Bridge(
 
 	go func() {
 		defer close(valStream)
+		defer panic_recovery.Recover("Bridge", panic_recovery.SendTo(done, valStream))
 
 		for {
 			var stream <-chan any
@@ -0,0 +1,249 @@
+package bulkhead
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/fan_out_fan_in"
+	"concurrency-patterns/panic_recovery"
+	"concurrency-patterns/pipeline"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// the bulkheads of a ship divide its hull into watertight compartments, so a leak floods one compartment instead of the ship.
+//
+// in a concurrent program, a single slow dependency can absorb every worker: each worker that calls it blocks,
+// until no worker is left for the calls to healthy dependencies. A bulkhead caps the number of concurrent calls to one
+// dependency class. Calls beyond the cap wait in a bounded queue, and once the queue is full, calls are rejected
+// immediately with ErrRejected - the slow dependency can only ever tie up its own share of the workers.
+
+// ErrRejected is returned for calls made while the bulkhead and its wait queue are full
+var ErrRejected = errors.New("bulkhead: full")
+
+// ErrUnknownClass is returned by Set for a dependency class it has no bulkhead for
+var ErrUnknownClass = errors.New("bulkhead: unknown dependency class")
+
+// ErrCanceled is returned if done was closed while waiting in the queue
+var ErrCanceled = errors.New("bulkhead: canceled")
+
+// Bulkhead caps the concurrent calls to one dependency class; it is safe for concurrent use
+type Bulkhead struct {
+	name  string
+	slots chan struct{} // one element per call in progress
+	queue chan struct{} // one element per waiting call
+
+	rejected atomic.Int64
+}
+
+// New returns a Bulkhead that allows maxConcurrent calls at a time, and lets up to maxQueue further calls wait
+func New(name string, maxConcurrent, maxQueue int) *Bulkhead {
+	return &Bulkhead{
+		name:  name,
+		slots: make(chan struct{}, max(maxConcurrent, 1)),
+		queue: make(chan struct{}, max(maxQueue, 0)),
+	}
+}
+
+// Execute calls fn once the bulkhead has room, and returns ErrRejected if it is full
+func (b *Bulkhead) Execute(done <-chan any, fn func() error) error {
+	_, err := Call(b, done, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+// Call calls fn once the bulkhead has room, and returns ErrRejected if it is full
+func Call[T any](b *Bulkhead, done <-chan any, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := b.acquire(done); err != nil {
+		return zero, err
+	}
+	defer b.release()
+
+	return fn()
+}
+
+// acquire takes a slot; calls that have to wait get their slots in the order they were queued
+func (b *Bulkhead) acquire(done <-chan any) error {
+	// fast path: there is a free slot, and no queued call that is about to take it
+	if len(b.queue) == 0 {
+		select {
+		case b.slots <- struct{}{}:
+			return nil
+		default:
+		}
+	}
+
+	// take a place in the queue, or reject the call. The runtime wakes goroutines blocked on sending to b.slots
+	// in the order they blocked, so the queue is FIFO
+	select {
+	case b.queue <- struct{}{}:
+	default:
+		b.rejected.Add(1)
+		return fmt.Errorf("%w: %s", ErrRejected, b.name)
+	}
+	defer func() { <-b.queue }()
+
+	select {
+	case <-done:
+		return fmt.Errorf("%w: %s", ErrCanceled, b.name)
+	case b.slots <- struct{}{}:
+		return nil
+	}
+}
+
+func (b *Bulkhead) release() {
+	<-b.slots
+}
+
+// Stats is a snapshot of the usage of a Bulkhead
+type Stats struct {
+	Name          string
+	InUse         int
+	MaxConcurrent int
+	Waiting       int
+	MaxQueue      int
+	Rejected      int64
+}
+
+// Stats returns the current usage of the bulkhead and the total number of rejected calls
+func (b *Bulkhead) Stats() Stats {
+	return Stats{
+		Name:          b.name,
+		InUse:         len(b.slots),
+		MaxConcurrent: cap(b.slots),
+		Waiting:       len(b.queue),
+		MaxQueue:      cap(b.queue),
+		Rejected:      b.rejected.Load(),
+	}
+}
+
+// Limits configures the bulkhead of a dependency class in a Set
+type Limits struct {
+	MaxConcurrent int
+	MaxQueue      int
+}
+
+// Set holds one Bulkhead per dependency class
+type Set struct {
+	bulkheads map[string]*Bulkhead
+}
+
+// NewSet returns a Set with a Bulkhead for every dependency class in limits
+func NewSet(limits map[string]Limits) *Set {
+	s := &Set{bulkheads: make(map[string]*Bulkhead, len(limits))}
+	for class, l := range limits {
+		s.bulkheads[class] = New(class, l.MaxConcurrent, l.MaxQueue)
+	}
+	return s
+}
+
+// Get returns the Bulkhead of class, or nil if there is none
+func (s *Set) Get(class string) *Bulkhead {
+	return s.bulkheads[class]
+}
+
+// Execute calls fn through the Bulkhead of class
+func (s *Set) Execute(done <-chan any, class string, fn func() error) error {
+	b := s.Get(class)
+	if b == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownClass, class)
+	}
+	return b.Execute(done, fn)
+}
+
+// Stats returns the usage of every Bulkhead in the set, sorted by dependency class
+func (s *Set) Stats() []Stats {
+	stats := make([]Stats, 0, len(s.bulkheads))
+	for _, b := range s.bulkheads {
+		stats = append(stats, b.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+
+	return stats
+}
+
+// Stage applies fn to every value of valueStream through the bulkhead
+//
+// rejected calls are sent downstream as results with ErrRejected. Fanning out several Stages that share one Bulkhead
+// gives a worker pool whose calls to the dependency are capped, independent of the number of workers.
+func Stage[T, R any](
+	done <-chan any,
+	valueStream <-chan T,
+	b *Bulkhead,
+	fn func(T) (R, error),
+) <-chan pipeline.Result[R] {
+	return pipeline.Apply(done, "bulkhead.Stage", valueStream, func(v T) (R, error) {
+		return Call(b, done, func() (R, error) { return fn(v) })
+	})
+}
+
+// BulkheadExec fans out 8 workers that call a slow and a fast dependency
+//
+// the slow dependency is capped at 2 concurrent calls with a queue of 2, so it can't tie up all the workers:
+// calls to the fast dependency keep flowing, and the excess calls to the slow one are rejected
+func BulkheadExec() {
+	bulkheadExec(clock.Real{})
+}
+
+// bulkheadExec takes the clock the dependencies sleep on as parameter, so a test can advance it instead of sleeping
+func bulkheadExec(clk clock.Clock) {
+	done := make(chan any)
+	defer close(done)
+
+	set := NewSet(map[string]Limits{
+		"slow": {MaxConcurrent: 2, MaxQueue: 2},
+		"fast": {MaxConcurrent: 8},
+	})
+
+	call := func(v any) any {
+		class := "fast"
+		d := time.Millisecond
+		if v.(int)%2 == 0 {
+			class = "slow"
+			d = 500 * time.Millisecond
+		}
+
+		err := set.Execute(done, class, func() error {
+			clk.Sleep(d)
+			return nil
+		})
+		if err != nil {
+			return fmt.Sprintf("%d (%s): %v", v, class, err)
+		}
+		return fmt.Sprintf("%d (%s): ok", v, class)
+	}
+
+	values := make([]int, 20)
+	for i := range values {
+		values[i] = i
+	}
+	valueStream := pipeline.Generator(done, values...)
+
+	workers := make([]<-chan any, 8)
+	for i := range workers {
+		workerStream := make(chan any)
+		workers[i] = workerStream
+
+		go func() {
+			defer close(workerStream)
+			defer panic_recovery.Recover("BulkheadExec", nil)
+
+			for v := range valueStream {
+				select {
+				case <-done:
+					return
+				case workerStream <- call(v):
+				}
+			}
+		}()
+	}
+
+	for result := range fan_out_fan_in.FanIn(done, workers...) {
+		fmt.Println(result)
+	}
+
+	for _, s := range set.Stats() {
+		fmt.Printf("%s: %d rejected\n", s.Name, s.Rejected)
+	}
+}
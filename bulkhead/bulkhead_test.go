@@ -0,0 +1,172 @@
+package bulkhead_test
+
+import (
+	"concurrency-patterns/bulkhead"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// block starts a call that holds its slot until release is closed, and waits until it has started
+func block(t *testing.T, b *bulkhead.Bulkhead, release <-chan any) {
+	t.Helper()
+
+	started := make(chan any)
+	go b.Execute(nil, func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+}
+
+// waitFor polls the stats of b until cond holds
+func waitFor(b *bulkhead.Bulkhead, cond func(bulkhead.Stats) bool) {
+	for !cond(b.Stats()) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBulkhead(t *testing.T) {
+	b := bulkhead.New("db", 2, 1)
+	release := make(chan any)
+
+	block(t, b, release)
+	block(t, b, release)
+
+	// the third call waits in the queue, the fourth is rejected
+	queued := make(chan error)
+	go func() { queued <- b.Execute(nil, func() error { return nil }) }()
+	waitFor(b, func(s bulkhead.Stats) bool { return s.Waiting == 1 })
+
+	if err := b.Execute(nil, func() error { return nil }); !errors.Is(err, bulkhead.ErrRejected) {
+		t.Errorf("got %v for a call beyond the queue, want ErrRejected", err)
+	}
+
+	want := bulkhead.Stats{Name: "db", InUse: 2, MaxConcurrent: 2, Waiting: 1, MaxQueue: 1, Rejected: 1}
+	if got := b.Stats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	close(release)
+	if err := <-queued; err != nil {
+		t.Errorf("got %v for the queued call, want it to run once a slot is free", err)
+	}
+	waitFor(b, func(s bulkhead.Stats) bool { return s.InUse == 0 && s.Waiting == 0 })
+}
+
+func TestBulkhead_ConcurrencyCap(t *testing.T) {
+	b := bulkhead.New("db", 3, 100)
+
+	var mu sync.Mutex
+	var inUse, peak int
+
+	results := make(chan error)
+	for i := 0; i < 50; i++ {
+		go func() {
+			results <- b.Execute(nil, func() error {
+				mu.Lock()
+				inUse++
+				peak = max(peak, inUse)
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				inUse--
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("got %v, want every call to be queued", err)
+		}
+	}
+	if peak > 3 {
+		t.Errorf("got %d concurrent calls, want at most 3", peak)
+	}
+}
+
+func TestBulkhead_CanceledWhileQueued(t *testing.T) {
+	b := bulkhead.New("db", 1, 1)
+	release := make(chan any)
+	defer close(release)
+
+	block(t, b, release)
+
+	done := make(chan any)
+	queued := make(chan error)
+	go func() { queued <- b.Execute(done, func() error { return nil }) }()
+	waitFor(b, func(s bulkhead.Stats) bool { return s.Waiting == 1 })
+
+	close(done)
+	if err := <-queued; !errors.Is(err, bulkhead.ErrCanceled) || !strings.Contains(err.Error(), "db") {
+		t.Errorf("got %v, want ErrCanceled with the name of the bulkhead", err)
+	}
+	if got := b.Stats().Waiting; got != 0 {
+		t.Errorf("got %d waiting calls, want the canceled call to leave the queue", got)
+	}
+}
+
+func TestBulkhead_FIFO(t *testing.T) {
+	b := bulkhead.New("db", 1, 3)
+	release := make(chan any)
+
+	block(t, b, release)
+
+	order := make(chan int, 3)
+	finished := make(chan error, 3)
+	for i := 1; i <= 3; i++ {
+		go func() { finished <- b.Execute(nil, func() error { order <- i; return nil }) }()
+		waitFor(b, func(s bulkhead.Stats) bool { return s.Waiting == i })
+
+		// the call takes its place in the queue just before it blocks on a slot; give it time to block
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	close(release)
+	for range 3 {
+		if err := <-finished; err != nil {
+			t.Fatalf("got %v, want every queued call to run", err)
+		}
+	}
+	close(order)
+
+	var got []int
+	for i := range order {
+		got = append(got, i)
+	}
+	if fmt.Sprint(got) != "[1 2 3]" {
+		t.Errorf("got the queued calls run in order %v, want [1 2 3]", got)
+	}
+}
+
+func TestSet(t *testing.T) {
+	set := bulkhead.NewSet(map[string]bulkhead.Limits{
+		"search":   {MaxConcurrent: 4},
+		"payments": {MaxConcurrent: 1, MaxQueue: 2},
+	})
+
+	if err := set.Execute(nil, "mail", func() error { return nil }); !errors.Is(err, bulkhead.ErrUnknownClass) {
+		t.Errorf("got %v, want ErrUnknownClass", err)
+	}
+
+	errFailed := errors.New("failed")
+	if err := set.Execute(nil, "search", func() error { return errFailed }); !errors.Is(err, errFailed) {
+		t.Errorf("got %v, want the error of the call", err)
+	}
+
+	stats := set.Stats()
+	if len(stats) != 2 || stats[0].Name != "payments" || stats[1].Name != "search" {
+		t.Fatalf("got %+v, want the stats of payments and search", stats)
+	}
+	if stats[0].MaxConcurrent != 1 || stats[0].MaxQueue != 2 || stats[1].MaxConcurrent != 4 {
+		t.Errorf("got %+v, want the configured limits", stats)
+	}
+}
@@ -0,0 +1,189 @@
+package checkpoint
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Checkpointing
+//
+// a long-running pipeline that crashes has to start over, unless it remembers how far it got.
+// A checkpointed source numbers the values it emits with an offset, and the sink at the end of the pipeline
+// acknowledges every value it has processed. The checkpoint persists the lowest offset that was not acknowledged yet,
+// so a restarted pipeline resumes there.
+//
+// values that were emitted but not acknowledged before the crash are emitted again - the pipeline processes every value
+// at least once. Sinks must therefore be idempotent or tolerate duplicates.
+
+// Item is a value emitted by a checkpointed source, together with its offset in the source
+//
+// if the source failed, Error is set instead; such an item has no offset, and doesn't need to be acknowledged.
+type Item struct {
+	Offset int64
+	Value  any
+	Error  error
+}
+
+// CarryError returns an Item with err, so the sources deliver their panics (see panic_recovery.SendTo)
+func (i Item) CarryError(err error) any {
+	return Item{Offset: -1, Error: err}
+}
+
+// MaxPendingAcks is the largest number of offsets a Checkpoint remembers while a gap before them stays open
+const MaxPendingAcks = 1 << 16
+
+// ErrGapTooLarge is returned by Ack for an offset at least MaxPendingAcks beyond the first unacknowledged one;
+// the ack is dropped, so the item is processed again after a restart
+var ErrGapTooLarge = errors.New("checkpoint: gap too large")
+
+// Checkpoint tracks the acknowledged offsets of a source
+//
+// it is safe for concurrent use, so several sinks (e.g. fanned-out workers) may acknowledge items out of order.
+type Checkpoint struct {
+	mu    sync.Mutex
+	store Store
+	name  string
+
+	next  int64          // every offset below next has been acknowledged
+	acked map[int64]bool // acknowledged offsets above next, waiting for the gap to be closed; at most MaxPendingAcks
+}
+
+// New returns the checkpoint of the source name, resuming at the offset saved in store
+func New(store Store, name string) (*Checkpoint, error) {
+	offset, _, err := store.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Checkpoint{store: store, name: name, next: offset, acked: make(map[int64]bool)}, nil
+}
+
+// Offset returns the offset a restarted source resumes at
+func (c *Checkpoint) Offset() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.next
+}
+
+// Ack marks the item at offset as processed
+//
+// the checkpoint only advances once every item before offset has been acknowledged as well; it is persisted whenever it advances.
+// While the gap stays open, the acknowledged offsets are kept in memory; to bound them, an offset MaxPendingAcks or more
+// beyond the gap is rejected with ErrGapTooLarge.
+func (c *Checkpoint) Ack(offset int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if offset < c.next {
+		return nil
+	}
+	if offset-c.next >= MaxPendingAcks {
+		return fmt.Errorf("%w: offset %d, first unacknowledged offset %d", ErrGapTooLarge, offset, c.next)
+	}
+
+	c.acked[offset] = true
+
+	advanced := false
+	for c.acked[c.next] {
+		delete(c.acked, c.next)
+		c.next++
+		advanced = true
+	}
+
+	if !advanced {
+		return nil
+	}
+	return c.store.Save(c.name, c.next)
+}
+
+// Generator emits the values starting at the checkpointed offset
+func (c *Checkpoint) Generator(done <-chan any, values ...any) <-chan Item {
+	itemStream := make(chan Item)
+
+	go func() {
+		defer close(itemStream)
+		defer panic_recovery.Recover("checkpoint.Generator", panic_recovery.SendTo(done, itemStream))
+
+		for offset := c.Offset(); offset < int64(len(values)); offset++ {
+			select {
+			case <-done:
+				return
+			case itemStream <- Item{Offset: offset, Value: values[offset]}:
+			}
+		}
+	}()
+
+	return itemStream
+}
+
+// RepeatFn calls fn with increasing offsets, starting at the checkpointed offset, until you tell it to stop
+//
+// fn must return the same value for the same offset, e.g. by reading the record at offset of a log.
+// A panic in fn is sent as an Item with Error, and ends the stream.
+func (c *Checkpoint) RepeatFn(done <-chan any, fn func(offset int64) any) <-chan Item {
+	itemStream := make(chan Item)
+
+	go func() {
+		defer close(itemStream)
+		defer panic_recovery.Recover("checkpoint.RepeatFn", panic_recovery.SendTo(done, itemStream))
+
+		for offset := c.Offset(); ; offset++ {
+			select {
+			case <-done:
+				return
+			case itemStream <- Item{Offset: offset, Value: fn(offset)}:
+			}
+		}
+	}()
+
+	return itemStream
+}
+
+// CheckpointExec processes a few values, "crashes", and resumes where it left off
+func CheckpointExec() {
+	dir, err := os.MkdirTemp("", "checkpoint")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	values := []any{"a", "b", "c", "d", "e", "f"}
+
+	run := func(crashAfter int) {
+		cp, err := New(store, "letters")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("starting at offset %d\n", cp.Offset())
+
+		done := make(chan any)
+		defer close(done)
+
+		processed := 0
+		for item := range cp.Generator(done, values...) {
+			if processed == crashAfter {
+				fmt.Println("crash!")
+				return
+			}
+
+			fmt.Printf("processed %v\n", item.Value)
+			cp.Ack(item.Offset)
+			processed++
+		}
+	}
+
+	run(3)
+	run(-1)
+}
@@ -0,0 +1,172 @@
+package checkpoint_test
+
+import (
+	"concurrency-patterns/checkpoint"
+	"concurrency-patterns/panic_recovery"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newCheckpoint(t *testing.T, store checkpoint.Store) *checkpoint.Checkpoint {
+	t.Helper()
+
+	cp, err := checkpoint.New(store, "source")
+	if err != nil {
+		t.Fatalf("new checkpoint: %v", err)
+	}
+	return cp
+}
+
+func TestCheckpoint_ResumeAfterCrash(t *testing.T) {
+	store, err := checkpoint.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := []any{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	// first run: acknowledge 4 items, receive a fifth one and crash before acknowledging it
+	cp := newCheckpoint(t, store)
+	done := make(chan any)
+	items := cp.Generator(done, values...)
+	for i := 0; i < 4; i++ {
+		if err := cp.Ack((<-items).Offset); err != nil {
+			t.Fatalf("ack: %v", err)
+		}
+	}
+	<-items
+	close(done)
+
+	// second run: resumes with the unacknowledged item
+	cp = newCheckpoint(t, store)
+	if got := cp.Offset(); got != 4 {
+		t.Fatalf("expected to resume at offset 4, got %d", got)
+	}
+
+	done = make(chan any)
+	defer close(done)
+
+	var got []any
+	for item := range cp.Generator(done, values...) {
+		got = append(got, item.Value)
+		cp.Ack(item.Offset)
+	}
+
+	if len(got) != 6 || got[0] != 4 || got[5] != 9 {
+		t.Fatalf("expected values 4 to 9, got %v", got)
+	}
+	if got := newCheckpoint(t, store).Offset(); got != 10 {
+		t.Fatalf("expected checkpoint at 10 after completion, got %d", got)
+	}
+}
+
+func TestCheckpoint_OutOfOrderAcks(t *testing.T) {
+	store, err := checkpoint.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp := newCheckpoint(t, store)
+	done := make(chan any)
+	items := cp.RepeatFn(done, func(offset int64) any { return offset * offset })
+
+	var received []checkpoint.Item
+	for i := 0; i < 5; i++ {
+		received = append(received, <-items)
+	}
+	close(done)
+
+	// offset 1 was never acknowledged, e.g. because the worker processing it crashed
+	for _, i := range []int{4, 2, 0, 3} {
+		cp.Ack(received[i].Offset)
+	}
+
+	if got := newCheckpoint(t, store).Offset(); got != 1 {
+		t.Fatalf("expected checkpoint to stop at the gap at offset 1, got %d", got)
+	}
+
+	cp.Ack(received[1].Offset)
+	if got := newCheckpoint(t, store).Offset(); got != 5 {
+		t.Fatalf("expected checkpoint to advance to 5 once the gap is closed, got %d", got)
+	}
+}
+
+func TestCheckpoint_PanicIsDelivered(t *testing.T) {
+	panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+	defer panic_recovery.SetSupervisor(nil)
+
+	store, err := checkpoint.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan any)
+	defer close(done)
+
+	items := newCheckpoint(t, store).RepeatFn(done, func(offset int64) any {
+		if offset == 2 {
+			panic("boom")
+		}
+		return offset
+	})
+
+	var got []checkpoint.Item
+	for item := range items {
+		got = append(got, item)
+	}
+
+	var panicErr *panic_recovery.PanicError
+	if len(got) != 3 || got[1].Offset != 1 || !errors.As(got[2].Error, &panicErr) {
+		t.Fatalf("expected offsets 0 and 1 followed by the panic, got %v", got)
+	}
+}
+
+func TestCheckpoint_GapTooLarge(t *testing.T) {
+	store, err := checkpoint.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp := newCheckpoint(t, store)
+
+	// offset 0 stays open, so every later ack is kept in memory, up to MaxPendingAcks
+	for offset := int64(1); offset < checkpoint.MaxPendingAcks; offset++ {
+		if err := cp.Ack(offset); err != nil {
+			t.Fatalf("ack %d: %v", offset, err)
+		}
+	}
+	if err := cp.Ack(checkpoint.MaxPendingAcks); !errors.Is(err, checkpoint.ErrGapTooLarge) {
+		t.Fatalf("expected ErrGapTooLarge, got %v", err)
+	}
+
+	// closing the gap advances the checkpoint up to the dropped ack
+	if err := cp.Ack(0); err != nil {
+		t.Fatalf("ack 0: %v", err)
+	}
+	if got := cp.Offset(); got != checkpoint.MaxPendingAcks {
+		t.Fatalf("expected checkpoint at %d, got %d", checkpoint.MaxPendingAcks, got)
+	}
+}
+
+func TestFileStore_InvalidName(t *testing.T) {
+	dir := t.TempDir()
+	store, err := checkpoint.NewFileStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"", "../escaped", "a/b", `a\b`} {
+		if err := store.Save(name, 1); !errors.Is(err, checkpoint.ErrInvalidName) {
+			t.Errorf("expected ErrInvalidName saving %q, got %v", name, err)
+		}
+		if _, _, err := store.Load(name); !errors.Is(err, checkpoint.ErrInvalidName) {
+			t.Errorf("expected ErrInvalidName loading %q, got %v", name, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "escaped.checkpoint")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no file outside the store's directory, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Store persists the offset at which a named source resumes
+type Store interface {
+	Load(name string) (offset int64, ok bool, err error)
+	Save(name string, offset int64) error
+}
+
+// ErrInvalidName is returned by FileStore for a source name that is empty or contains a path separator
+var ErrInvalidName = errors.New("checkpoint: invalid source name")
+
+// FileStore is a Store that keeps one file per source in a local directory
+//
+// a source name becomes a file name, so it must not contain a path separator - "../x" would escape the directory.
+//
+// offsets are written to a temporary file that is renamed afterwards,
+// so a crash while saving leaves either the old or the new offset behind - never a torn one.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore that keeps its files in dir, creating dir if necessary
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, os.PathSeparator) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return filepath.Join(s.dir, name+".checkpoint"), nil
+}
+
+func (s *FileStore) Load(name string) (int64, bool, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return 0, false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("checkpoint: load %s: %w", name, err)
+	}
+
+	return offset, true, nil
+}
+
+func (s *FileStore) Save(name string, offset int64) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d\n", offset); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
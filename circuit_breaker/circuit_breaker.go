@@ -0,0 +1,288 @@
+package circuit_breaker
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/pipeline"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// a circuit breaker protects a pipeline from a failing dependency - and the dependency from the pipeline.
+//
+// while the breaker is closed, calls pass through, and consecutive failures are counted. Once they reach a threshold,
+// the breaker opens: calls fail immediately with ErrOpen instead of each paying the full timeout of the failing dependency.
+// After a cool-down, the breaker becomes half-open and lets a few trial calls through. If they succeed, the breaker closes
+// again; if one of them fails, it opens for another cool-down.
+
+// ErrOpen is returned for calls the breaker short-circuited
+var ErrOpen = errors.New("circuit breaker: open")
+
+// State is the state of a Breaker
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// StateChange is emitted on the Events channel whenever the breaker changes its state
+type StateChange struct {
+	From, To State
+	At       time.Time
+}
+
+// Config configures a Breaker; zero values are replaced by the defaults in brackets
+type Config struct {
+	FailureThreshold int              // consecutive failures that open the breaker [5]
+	SuccessThreshold int              // consecutive successful trial calls that close a half-open breaker [1]
+	HalfOpenMaxCalls int              // concurrent trial calls while half-open [1]
+	CoolDown         time.Duration    // time the breaker stays open [30s]
+	IsFailure        func(error) bool // decides which errors count as failures [every error]
+	Events           int              // buffer size of the Events channel [16]
+	Clock            clock.Clock      // [clock.Real]
+}
+
+// Breaker is a circuit breaker; it is safe for concurrent use
+type Breaker struct {
+	mu  sync.Mutex
+	cfg Config
+	clk clock.Clock
+
+	state         State
+	failures      int
+	successes     int
+	halfOpenCalls int
+	openedAt      time.Time
+	generation    uint64 // bumped by every transition, so outcomes of calls admitted in an earlier state are ignored
+
+	events chan StateChange
+}
+
+// New returns a closed Breaker
+func New(cfg Config) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = 30 * time.Second
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool { return err != nil }
+	}
+	if cfg.Events <= 0 {
+		cfg.Events = 16
+	}
+
+	return &Breaker{cfg: cfg, clk: clock.OrReal(cfg.Clock), events: make(chan StateChange, cfg.Events)}
+}
+
+// Events returns the channel state changes are emitted on
+//
+// the breaker never blocks on an observer: if the buffer is full, the state change is dropped.
+func (b *Breaker) Events() <-chan StateChange {
+	return b.events
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.coolDown()
+	return b.state
+}
+
+// Execute calls fn if the breaker allows it, and records the outcome
+func (b *Breaker) Execute(fn func() error) error {
+	_, err := Call(b, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+// Call calls fn if the breaker allows it, and records the outcome
+//
+// if fn panics, the call is recorded as a failure before the panic continues, so a half-open trial slot isn't lost.
+func Call[T any](b *Breaker, fn func() (T, error)) (T, error) {
+	generation, err := b.allow()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	panicked := true
+	defer func() {
+		if panicked {
+			b.record(generation, true)
+		}
+	}()
+
+	v, err := fn()
+	panicked = false
+	b.record(generation, b.cfg.IsFailure(err))
+
+	return v, err
+}
+
+// allow reports whether a call may pass, and returns the generation of the state that admitted it
+func (b *Breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.coolDown()
+
+	switch b.state {
+	case Open:
+		return 0, ErrOpen
+	case HalfOpen:
+		if b.halfOpenCalls >= b.cfg.HalfOpenMaxCalls {
+			return 0, ErrOpen
+		}
+		b.halfOpenCalls++
+	}
+	return b.generation, nil
+}
+
+// record updates the state with the outcome of a call admitted in the given generation
+func (b *Breaker) record(generation uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the call was admitted before the last transition - e.g. a slow call admitted while closed, which finishes
+	// while half-open. Its outcome says nothing about the current state, and it holds no trial slot.
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case Closed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		if b.failures++; b.failures >= b.cfg.FailureThreshold {
+			b.transition(Open)
+		}
+	case HalfOpen:
+		b.halfOpenCalls--
+		if failed {
+			b.transition(Open)
+			return
+		}
+		if b.successes++; b.successes >= b.cfg.SuccessThreshold {
+			b.transition(Closed)
+		}
+	}
+}
+
+// coolDown moves an open breaker to half-open once the cool-down has passed
+func (b *Breaker) coolDown() {
+	if b.state == Open && b.clk.Since(b.openedAt) >= b.cfg.CoolDown {
+		b.transition(HalfOpen)
+	}
+}
+
+func (b *Breaker) transition(to State) {
+	change := StateChange{From: b.state, To: to, At: b.clk.Now()}
+
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.successes = 0
+	b.halfOpenCalls = 0
+	if to == Open {
+		b.openedAt = change.At
+	}
+
+	select {
+	case b.events <- change:
+	default:
+	}
+}
+
+// Stage applies fn to every value of valueStream through the breaker
+//
+// short-circuited calls are sent downstream as results with ErrOpen, so the consumer can tell them apart from failed calls.
+func Stage[T, R any](
+	done <-chan any,
+	valueStream <-chan T,
+	b *Breaker,
+	fn func(T) (R, error),
+) <-chan pipeline.Result[R] {
+	return pipeline.Apply(done, "circuit_breaker.Stage", valueStream, func(v T) (R, error) {
+		return Call(b, func() (R, error) { return fn(v) })
+	})
+}
+
+// CircuitBreakerExec checks the status of a failing server through a circuit breaker
+//
+// after three failures the breaker opens, and the remaining checks fail immediately with ErrOpen
+// instead of each waiting for the failing server
+func CircuitBreakerExec() {
+	done := make(chan any)
+	defer close(done)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	breaker := New(Config{FailureThreshold: 3, CoolDown: time.Minute})
+
+	getStatus := func(url string) (string, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		return resp.Status, nil
+	}
+
+	urls := make(chan string)
+	go func() {
+		defer close(urls)
+		for range 5 {
+			select {
+			case <-done:
+				return
+			case urls <- "https://www.badass":
+			}
+		}
+	}()
+
+	for result := range Stage(done, urls, breaker, getStatus) {
+		switch {
+		case errors.Is(result.Error, ErrOpen):
+			fmt.Println("short-circuited")
+		case result.Error != nil:
+			fmt.Printf("error: %v\n", result.Error)
+		default:
+			fmt.Printf("Response: %v\n", result.Value)
+		}
+	}
+
+	for {
+		select {
+		case change := <-breaker.Events():
+			fmt.Printf("breaker: %v -> %v\n", change.From, change.To)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package circuit_breaker_test
+
+import (
+	"concurrency-patterns/circuit_breaker"
+	"concurrency-patterns/clock"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFailed = errors.New("failed")
+
+func fail() error    { return errFailed }
+func succeed() error { return nil }
+
+func TestCall_PanicReleasesTrialSlot(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	b := circuit_breaker.New(circuit_breaker.Config{FailureThreshold: 1, CoolDown: time.Minute, Clock: clk})
+
+	b.Execute(fail)
+	clk.Advance(time.Minute)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic of the trial call was swallowed")
+			}
+		}()
+		b.Execute(func() error { panic("boom") })
+	}()
+
+	// the panicking trial call counts as a failure, so the breaker opens for another cool-down instead of staying half-open
+	if got := b.State(); got != circuit_breaker.Open {
+		t.Fatalf("got %v after a panicking trial call, want open", got)
+	}
+	clk.Advance(time.Minute)
+	if err := b.Execute(succeed); err != nil {
+		t.Errorf("got %v after the cool-down, want the trial call to pass", err)
+	}
+}
+
+func TestCall_OutcomeOfEarlierState(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	b := circuit_breaker.New(circuit_breaker.Config{FailureThreshold: 1, CoolDown: time.Minute, Clock: clk})
+
+	// a slow call is admitted while closed, and only finishes once the breaker is half-open
+	admitted, finish := make(chan any), make(chan any)
+	slow := make(chan error)
+	go func() {
+		slow <- b.Execute(func() error {
+			close(admitted)
+			<-finish
+			return nil
+		})
+	}()
+	<-admitted
+
+	b.Execute(fail)
+	clk.Advance(time.Minute)
+	if got := b.State(); got != circuit_breaker.HalfOpen {
+		t.Fatalf("got %v, want half-open", got)
+	}
+
+	close(finish)
+	<-slow
+
+	// the slow success must neither close the breaker nor free a trial slot it never held
+	if got := b.State(); got != circuit_breaker.HalfOpen {
+		t.Errorf("got %v after the slow call finished, want half-open", got)
+	}
+
+	trial, finishTrial := make(chan any), make(chan any)
+	go b.Execute(func() error {
+		close(trial)
+		<-finishTrial
+		return nil
+	})
+	<-trial
+	if err := b.Execute(succeed); !errors.Is(err, circuit_breaker.ErrOpen) {
+		t.Errorf("got %v for a second concurrent trial call, want ErrOpen", err)
+	}
+	close(finishTrial)
+}
+
+func TestBreaker_States(t *testing.T) {
+	tests := []struct {
+		name   string
+		trials []func() error
+		want   circuit_breaker.State
+		events []circuit_breaker.State
+	}{
+		{
+			name:   "half-open trials succeed",
+			trials: []func() error{succeed, succeed},
+			want:   circuit_breaker.Closed,
+			events: []circuit_breaker.State{circuit_breaker.Open, circuit_breaker.HalfOpen, circuit_breaker.Closed},
+		},
+		{
+			name:   "not enough successful trials",
+			trials: []func() error{succeed},
+			want:   circuit_breaker.HalfOpen,
+			events: []circuit_breaker.State{circuit_breaker.Open, circuit_breaker.HalfOpen},
+		},
+		{
+			name:   "half-open trial fails",
+			trials: []func() error{succeed, fail},
+			want:   circuit_breaker.Open,
+			events: []circuit_breaker.State{circuit_breaker.Open, circuit_breaker.HalfOpen, circuit_breaker.Open},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := clock.NewFake(time.Now())
+			b := circuit_breaker.New(circuit_breaker.Config{
+				FailureThreshold: 3,
+				SuccessThreshold: 2,
+				CoolDown:         time.Minute,
+				Clock:            clk,
+			})
+
+			// a success resets the count of consecutive failures
+			for _, fn := range []func() error{fail, fail, succeed, fail, fail} {
+				b.Execute(fn)
+			}
+			if got := b.State(); got != circuit_breaker.Closed {
+				t.Fatalf("got %v below the failure threshold, want closed", got)
+			}
+
+			b.Execute(fail)
+			if got := b.State(); got != circuit_breaker.Open {
+				t.Fatalf("got %v at the failure threshold, want open", got)
+			}
+
+			called := false
+			if err := b.Execute(func() error { called = true; return nil }); !errors.Is(err, circuit_breaker.ErrOpen) || called {
+				t.Fatalf("got %v (called: %v) while open, want ErrOpen without a call", err, called)
+			}
+
+			clk.Advance(time.Minute - time.Nanosecond)
+			if got := b.State(); got != circuit_breaker.Open {
+				t.Fatalf("got %v before the cool-down has passed, want open", got)
+			}
+			clk.Advance(time.Nanosecond)
+			if got := b.State(); got != circuit_breaker.HalfOpen {
+				t.Fatalf("got %v after the cool-down, want half-open", got)
+			}
+
+			for _, trial := range tt.trials {
+				b.Execute(trial)
+			}
+			if got := b.State(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			for i, want := range tt.events {
+				select {
+				case change := <-b.Events():
+					if change.To != want {
+						t.Errorf("event %d: got %v -> %v, want a change to %v", i, change.From, change.To, want)
+					}
+				default:
+					t.Fatalf("got %d events, want %d", i, len(tt.events))
+				}
+			}
+			select {
+			case change := <-b.Events():
+				t.Errorf("got the unexpected event %v -> %v", change.From, change.To)
+			default:
+			}
+		})
+	}
+}
+
+func TestBreaker_IsFailure(t *testing.T) {
+	errNotFound := errors.New("not found")
+	b := circuit_breaker.New(circuit_breaker.Config{
+		FailureThreshold: 1,
+		IsFailure:        func(err error) bool { return err != nil && !errors.Is(err, errNotFound) },
+		Clock:            clock.NewFake(time.Now()),
+	})
+
+	b.Execute(func() error { return errNotFound })
+	if got := b.State(); got != circuit_breaker.Closed {
+		t.Errorf("got %v after an error that is no failure, want closed", got)
+	}
+}
+
+func TestStage(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	b := circuit_breaker.New(circuit_breaker.Config{FailureThreshold: 2, Clock: clock.NewFake(time.Now())})
+
+	valueStream := make(chan int)
+	go func() {
+		defer close(valueStream)
+		for i := 0; i < 5; i++ {
+			valueStream <- i
+		}
+	}()
+
+	var failed, shortCircuited int
+	for result := range circuit_breaker.Stage(done, valueStream, b, func(i int) (int, error) { return 0, errFailed }) {
+		switch {
+		case errors.Is(result.Error, circuit_breaker.ErrOpen):
+			shortCircuited++
+		case errors.Is(result.Error, errFailed):
+			failed++
+		default:
+			t.Errorf("got %+v, want an error", result)
+		}
+	}
+
+	if failed != 2 || shortCircuited != 3 {
+		t.Errorf("got %d failed and %d short-circuited calls, want 2 and 3", failed, shortCircuited)
+	}
+}
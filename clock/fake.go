@@ -0,0 +1,200 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when it is told to
+//
+// timers, tickers and sleepers fire in the order of their deadlines when Advance moves the clock past them.
+// Since the goroutine under test may not have started its timer yet when the test calls Advance,
+// tests use BlockUntil to wait for the expected number of timers first.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+// NewFake returns a Fake clock set to now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+type fakeTimer struct {
+	f        *Fake
+	c        chan time.Time
+	deadline time.Time
+	period   time.Duration // only set for tickers
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{f: f, c: make(chan time.Time, 1)}
+	f.schedule(t, d)
+	return t
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+
+	t := &fakeTimer{f: f, c: make(chan time.Time, 1), period: d}
+	f.schedule(t, d)
+	return fakeTicker{t}
+}
+
+// Advance moves the clock forward by d and fires every timer whose deadline has passed
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	target := f.now.Add(d)
+	for {
+		t := f.earliest()
+		if t == nil || t.deadline.After(target) {
+			break
+		}
+
+		f.now = t.deadline
+		f.fire(t)
+	}
+	f.now = target
+}
+
+// BlockUntil blocks until at least n timers, tickers or sleepers are waiting on the clock
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// Waiters returns the number of timers, tickers and sleepers waiting on the clock
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.waiters)
+}
+
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t.deadline = f.now.Add(d)
+	if d <= 0 {
+		f.fire(t)
+		return
+	}
+
+	f.waiters = append(f.waiters, t)
+	f.cond.Broadcast()
+}
+
+// earliest returns the waiting timer with the earliest deadline; the first one registered wins a tie
+func (f *Fake) earliest() *fakeTimer {
+	var first *fakeTimer
+	for _, t := range f.waiters {
+		if first == nil || t.deadline.Before(first.deadline) {
+			first = t
+		}
+	}
+	return first
+}
+
+// fire sends the current time on t's channel, dropping the tick if the previous one wasn't received (like time.Ticker)
+func (f *Fake) fire(t *fakeTimer) {
+	select {
+	case t.c <- f.now:
+	default:
+	}
+
+	if t.period > 0 {
+		t.deadline = t.deadline.Add(t.period)
+		return
+	}
+	f.remove(t)
+}
+
+func (f *Fake) remove(t *fakeTimer) bool {
+	for i, w := range f.waiters {
+		if w == t {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop stops the timer; like time.Timer since Go 1.23, no stale value is received after Stop returns
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+
+	t.drain()
+	return t.f.remove(t)
+}
+
+// Reset changes the timer to expire after d; like time.Timer since Go 1.23, no stale value is received afterwards
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.f.mu.Lock()
+	active := t.f.remove(t)
+	t.drain()
+	t.f.mu.Unlock()
+
+	t.f.schedule(t, d)
+	return active
+}
+
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (t fakeTicker) C() <-chan time.Time { return t.t.c }
+func (t fakeTicker) Stop()               { t.t.Stop() }
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+
+	t.t.f.mu.Lock()
+	t.t.period = d
+	t.t.f.mu.Unlock()
+
+	t.t.Reset(d)
+}
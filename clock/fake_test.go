@@ -0,0 +1,207 @@
+package clock_test
+
+import (
+	"concurrency-patterns/clock"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// received returns the value waiting on c, if there is one
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFake_Advance(t *testing.T) {
+	clk := clock.NewFake(start)
+
+	// every timer observes the clock at its own deadline, even though a single Advance passed all of them
+	var fired []time.Duration
+	timers := []clock.Timer{clk.NewTimer(3 * time.Second), clk.NewTimer(time.Second), clk.NewTimer(2 * time.Second)}
+	late := clk.NewTimer(10 * time.Second)
+
+	clk.Advance(5 * time.Second)
+
+	for _, timer := range timers {
+		at, ok := received(timer.C())
+		if !ok {
+			t.Fatalf("a timer didn't fire")
+		}
+		fired = append(fired, at.Sub(start))
+	}
+	if want := []time.Duration{3 * time.Second, time.Second, 2 * time.Second}; fmt.Sprint(fired) != fmt.Sprint(want) {
+		t.Errorf("got the timers fired at %v, want %v", fired, want)
+	}
+
+	if _, ok := received(late.C()); ok {
+		t.Errorf("the timer beyond the advanced time fired")
+	}
+	if got := clk.Since(start); got != 5*time.Second {
+		t.Errorf("got the clock at %v, want 5s", got)
+	}
+	if got := clk.Waiters(); got != 1 {
+		t.Errorf("got %d waiters, want only the late timer", got)
+	}
+}
+
+func TestFake_ZeroDuration(t *testing.T) {
+	clk := clock.NewFake(start)
+
+	if _, ok := received(clk.After(0)); !ok {
+		t.Errorf("a timer with a zero duration didn't fire immediately")
+	}
+	if got := clk.Waiters(); got != 0 {
+		t.Errorf("got %d waiters, want 0", got)
+	}
+}
+
+func TestFake_Ticker(t *testing.T) {
+	clk := clock.NewFake(start)
+	ticker := clk.NewTicker(time.Second)
+
+	clk.Advance(time.Second)
+	if at, ok := received(ticker.C()); !ok || at != start.Add(time.Second) {
+		t.Errorf("got tick %v, %v, want one at 1s", at, ok)
+	}
+
+	// nobody received the tick at 2s, so the ones at 3s and 4s are dropped, like with time.Ticker
+	clk.Advance(3 * time.Second)
+	if at, ok := received(ticker.C()); !ok || at != start.Add(2*time.Second) {
+		t.Errorf("got tick %v, %v, want the one at 2s", at, ok)
+	}
+	if at, ok := received(ticker.C()); ok {
+		t.Errorf("got tick %v, want the later ones dropped", at)
+	}
+
+	// the ticker keeps its period
+	clk.Advance(time.Second)
+	if at, ok := received(ticker.C()); !ok || at != start.Add(5*time.Second) {
+		t.Errorf("got tick %v, %v, want one at 5s", at, ok)
+	}
+
+	ticker.Reset(3 * time.Second)
+	clk.Advance(2 * time.Second)
+	if at, ok := received(ticker.C()); ok {
+		t.Errorf("got tick %v before the new period passed", at)
+	}
+	clk.Advance(time.Second)
+	if at, ok := received(ticker.C()); !ok || at != start.Add(8*time.Second) {
+		t.Errorf("got tick %v, %v, want one at 8s after the reset", at, ok)
+	}
+
+	ticker.Stop()
+	clk.Advance(time.Hour)
+	if at, ok := received(ticker.C()); ok {
+		t.Errorf("got tick %v after Stop", at)
+	}
+}
+
+func TestFake_TickerNonPositive(t *testing.T) {
+	clk := clock.NewFake(start)
+	ticker := clk.NewTicker(time.Second)
+
+	// like time.Ticker, a ticker can't be created or reset with a non-positive interval
+	for name, fn := range map[string]func(){
+		"NewTicker":  func() { clk.NewTicker(0) },
+		"Reset(0)":   func() { ticker.Reset(0) },
+		"Reset(-1s)": func() { ticker.Reset(-time.Second) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s didn't panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestFake_Stop(t *testing.T) {
+	clk := clock.NewFake(start)
+
+	pending := clk.NewTimer(time.Second)
+	if !pending.Stop() {
+		t.Errorf("Stop of a pending timer returned false")
+	}
+
+	// the timer fired, but its value wasn't received: Stop drains it
+	expired := clk.NewTimer(time.Second)
+	clk.Advance(time.Second)
+	if expired.Stop() {
+		t.Errorf("Stop of an expired timer returned true")
+	}
+
+	clk.Advance(time.Hour)
+	for _, timer := range []clock.Timer{pending, expired} {
+		if at, ok := received(timer.C()); ok {
+			t.Errorf("got %v from a stopped timer", at)
+		}
+	}
+}
+
+func TestFake_Reset(t *testing.T) {
+	clk := clock.NewFake(start)
+
+	timer := clk.NewTimer(time.Second)
+	if !timer.Reset(2 * time.Second) {
+		t.Errorf("Reset of a pending timer returned false")
+	}
+	clk.Advance(time.Second)
+	if at, ok := received(timer.C()); ok {
+		t.Errorf("got %v at the original deadline", at)
+	}
+
+	// the timer fired, but its value wasn't received: Reset drains it, so only the new deadline is seen
+	clk.Advance(time.Second)
+	if timer.Reset(time.Second) {
+		t.Errorf("Reset of an expired timer returned true")
+	}
+	if at, ok := received(timer.C()); ok {
+		t.Errorf("got the stale value %v after Reset", at)
+	}
+
+	clk.Advance(time.Second)
+	if at, ok := received(timer.C()); !ok || at != start.Add(3*time.Second) {
+		t.Errorf("got %v, %v, want the new deadline at 3s", at, ok)
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	clk := clock.NewFake(start)
+
+	woke := make(chan any)
+	for range 2 {
+		go func() {
+			clk.Sleep(time.Second)
+			woke <- struct{}{}
+		}()
+	}
+
+	clk.BlockUntil(2)
+	if got := clk.Waiters(); got != 2 {
+		t.Errorf("got %d waiters, want 2", got)
+	}
+
+	select {
+	case <-woke:
+		t.Fatalf("a sleeper woke before the clock was advanced")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clk.Advance(time.Second)
+	for range 2 {
+		select {
+		case <-woke:
+		case <-time.After(time.Second):
+			t.Fatalf("a sleeper didn't wake after the clock was advanced")
+		}
+	}
+}
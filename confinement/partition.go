@@ -0,0 +1,142 @@
+package confinement
+
+import (
+	"concurrency-patterns/or_channel"
+	"concurrency-patterns/panic_recovery"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ####### EXAMPLE 3 ####### - start
+
+// example 2 splits the data by hand: data[:3] and data[3:]. The helpers below do this for any slice,
+// and guarantee confinement by construction:
+//
+// 1. the chunks are disjoint, so no two goroutines ever see the same element
+// 2. every chunk is cut with a full slice expression (data[low:high:high]), so its capacity ends where the chunk ends.
+//    An append to a chunk therefore allocates a new array instead of silently overwriting the first elements of the next chunk
+// 3. the map function only receives its chunk - like printData, it can't close around the whole slice
+
+// ErrCanceled is returned by MapReduce if done was closed before every chunk was processed
+var ErrCanceled = errors.New("confinement: canceled")
+
+// Partition splits data into n disjoint chunks whose sizes differ by at most one element
+func Partition[T any](data []T, n int) [][]T {
+	n = max(min(n, len(data)), 1)
+
+	chunks := make([][]T, 0, n)
+	low := 0
+	for i := 0; i < n; i++ {
+		// spread the remainder over the first chunks
+		high := low + len(data)/n
+		if i < len(data)%n {
+			high++
+		}
+
+		chunks = append(chunks, data[low:high:high])
+		low = high
+	}
+
+	return chunks
+}
+
+// PartitionBySize splits data into disjoint chunks of at most size elements
+func PartitionBySize[T any](data []T, size int) [][]T {
+	size = max(size, 1)
+
+	chunks := make([][]T, 0, (len(data)+size-1)/size)
+	for low := 0; low < len(data); low += size {
+		high := min(low+size, len(data))
+		chunks = append(chunks, data[low:high:high])
+	}
+
+	return chunks
+}
+
+// MapReduce processes every chunk in its own goroutine with mapFn, and merges the results with reduce
+//
+// the results are reduced in the order of the chunks, starting with initial, so the outcome doesn't depend on
+// which goroutine finishes first. mapFn receives a done channel so it can stop early: it's closed when the caller's
+// done is closed, or when another chunk failed. The first error returned by mapFn is returned by MapReduce;
+// a panic in mapFn is returned as *panic_recovery.PanicError. If done is closed before every chunk was processed,
+// MapReduce returns ErrCanceled.
+func MapReduce[T, R any](
+	done <-chan any,
+	chunks [][]T,
+	mapFn func(done <-chan any, chunk []T) (R, error),
+	reduce func(acc, r R) R,
+	initial R,
+) (R, error) {
+	type chunkResult struct {
+		index int
+		value R
+		err   error
+	}
+
+	// buffered, so the goroutines never block on reporting, even if MapReduce was cancelled
+	resultStream := make(chan chunkResult, len(chunks))
+
+	// closed once MapReduce returns - on the first error, the remaining chunks are abandoned, so mapFn may stop early
+	cancel := make(chan any)
+	defer close(cancel)
+	mapDone := or_channel.Or(done, cancel)
+
+	for i, chunk := range chunks {
+		go func() {
+			defer panic_recovery.Recover("MapReduce", func(err *panic_recovery.PanicError) {
+				resultStream <- chunkResult{index: i, err: err}
+			})
+
+			value, err := mapFn(mapDone, chunk)
+			resultStream <- chunkResult{index: i, value: value, err: err}
+		}()
+	}
+
+	results := make([]R, len(chunks))
+	for range chunks {
+		select {
+		case <-done:
+			return initial, ErrCanceled
+		case r := <-resultStream:
+			if r.err != nil {
+				return initial, r.err
+			}
+			results[r.index] = r.value
+		}
+	}
+
+	acc := initial
+	for _, r := range results {
+		acc = reduce(acc, r)
+	}
+
+	return acc, nil
+}
+
+func confinementExec_example3() {
+	done := make(chan any)
+	defer close(done)
+
+	data := []byte("golang")
+
+	upper, err := MapReduce(done, Partition(data, 2),
+		func(done <-chan any, chunk []byte) (string, error) {
+			// only the chunk is visible here, so it's safe to modify it in place
+			for i, b := range chunk {
+				chunk[i] = strings.ToUpper(string(b))[0]
+			}
+			return string(chunk), nil
+		},
+		func(acc, r string) string { return acc + r },
+		"",
+	)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+
+	fmt.Println(upper)
+}
+
+// ####### EXAMPLE 3 ####### - end
@@ -0,0 +1,194 @@
+package confinement_test
+
+import (
+	"concurrency-patterns/confinement"
+	"concurrency-patterns/panic_recovery"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		name string
+		data []int
+		n    int
+		want [][]int
+	}{
+		{"even", []int{1, 2, 3, 4, 5, 6}, 3, [][]int{{1, 2}, {3, 4}, {5, 6}}},
+		{"remainder spread over the first chunks", []int{1, 2, 3, 4, 5, 6, 7, 8}, 3, [][]int{{1, 2, 3}, {4, 5, 6}, {7, 8}}},
+		{"more chunks than elements", []int{1, 2}, 5, [][]int{{1}, {2}}},
+		{"zero chunks", []int{1, 2, 3}, 0, [][]int{{1, 2, 3}}},
+		{"empty slice", nil, 3, [][]int{{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := confinement.Partition(tt.data, tt.n); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionBySize(t *testing.T) {
+	tests := []struct {
+		name string
+		data []int
+		size int
+		want [][]int
+	}{
+		{"even", []int{1, 2, 3, 4}, 2, [][]int{{1, 2}, {3, 4}}},
+		{"short last chunk", []int{1, 2, 3, 4, 5}, 2, [][]int{{1, 2}, {3, 4}, {5}}},
+		{"size beyond the slice", []int{1, 2}, 5, [][]int{{1, 2}}},
+		{"zero size", []int{1, 2}, 0, [][]int{{1}, {2}}},
+		{"empty slice", nil, 3, [][]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := confinement.PartitionBySize(tt.data, tt.size); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartition_CapacityCapped(t *testing.T) {
+	data := []int{1, 2, 3, 4, 5, 6}
+
+	for name, chunks := range map[string][][]int{
+		"Partition":       confinement.Partition(data, 3),
+		"PartitionBySize": confinement.PartitionBySize(data, 2),
+	} {
+		for _, chunk := range chunks {
+			if cap(chunk) != len(chunk) {
+				t.Errorf("%s: chunk %v has capacity %d, want %d", name, chunk, cap(chunk), len(chunk))
+			}
+		}
+
+		// an append to the first chunk must not overwrite the second one
+		_ = append(chunks[0], 100)
+		if chunks[1][0] != 3 {
+			t.Errorf("%s: append to the first chunk overwrote the second one: %v", name, chunks[1])
+		}
+	}
+}
+
+func TestMapReduce(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	// the first chunk finishes last, but the results are still reduced in the order of the chunks
+	chunks := confinement.PartitionBySize([]int{1, 2, 3, 4, 5, 6, 7, 8, 9}, 2)
+	got, err := confinement.MapReduce(done, chunks,
+		func(done <-chan any, chunk []int) (string, error) {
+			time.Sleep(time.Duration(len(chunks)-chunk[0]/2) * time.Millisecond)
+			return fmt.Sprint(chunk), nil
+		},
+		func(acc, r string) string { return acc + r },
+		">",
+	)
+
+	if want := ">[1 2][3 4][5 6][7 8][9]"; err != nil || got != want {
+		t.Errorf("got %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestMapReduce_Panic(t *testing.T) {
+	panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+	defer panic_recovery.SetSupervisor(nil)
+
+	done := make(chan any)
+	defer close(done)
+
+	// the other chunks only return once the failing chunk closed their done channel
+	stopped := make(chan int, 3)
+	got, err := confinement.MapReduce(done, confinement.Partition([]int{1, 2, 3, 4}, 4),
+		func(done <-chan any, chunk []int) (int, error) {
+			if chunk[0] == 3 {
+				panic("boom")
+			}
+			<-done
+			stopped <- chunk[0]
+			return chunk[0], nil
+		},
+		func(acc, r int) int { return acc + r },
+		-1,
+	)
+
+	var panicErr *panic_recovery.PanicError
+	if !errors.As(err, &panicErr) || got != -1 {
+		t.Errorf("got %v, %v, want -1 and the recovered panic", got, err)
+	}
+
+	for range 3 {
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatalf("the other chunks were not stopped after the error")
+		}
+	}
+}
+
+func TestMapReduce_Error(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	// the other chunks only return once the failing chunk closed their done channel
+	errBoom := errors.New("boom")
+	stopped := make(chan int, 3)
+	got, err := confinement.MapReduce(done, confinement.Partition([]int{1, 2, 3, 4}, 4),
+		func(done <-chan any, chunk []int) (int, error) {
+			if chunk[0] == 3 {
+				return 0, errBoom
+			}
+			<-done
+			stopped <- chunk[0]
+			return chunk[0], nil
+		},
+		func(acc, r int) int { return acc + r },
+		-1,
+	)
+
+	if !errors.Is(err, errBoom) || got != -1 {
+		t.Errorf("got %v, %v, want -1 and the error of the failing chunk", got, err)
+	}
+
+	for range 3 {
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatalf("the other chunks were not stopped after the error")
+		}
+	}
+}
+
+func TestMapReduce_Canceled(t *testing.T) {
+	done := make(chan any)
+
+	stopped := make(chan any)
+	go func() {
+		defer close(stopped)
+
+		got, err := confinement.MapReduce(done, confinement.Partition([]int{1, 2, 3}, 3),
+			func(done <-chan any, chunk []int) (int, error) {
+				<-done
+				return chunk[0], nil
+			},
+			func(acc, r int) int { return acc + r },
+			0,
+		)
+		if !errors.Is(err, confinement.ErrCanceled) || got != 0 {
+			t.Errorf("got %v, %v, want 0 and ErrCanceled", got, err)
+		}
+	}()
+
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("MapReduce did not return after done was closed")
+	}
+}
@@ -0,0 +1,172 @@
+package error_handling
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+// Error is a well-formed error, as described by Katherine Cox-Buday
+//
+// errors indicate that your system has entered a state in which it cannot fulfill an operation that a user either
+// explicitly or implicitly requested. Because of this, it needs to relay a few pieces of critical information:
+//
+// 1. what happened: the low-level error (Inner)
+// 2. when and where it occurred: the stack trace, the goroutine, the stage and the module boundary it crossed
+// 3. a friendly user-facing message (Message)
+// 4. how the user can get more information: the correlation ID, which ties the message to the logs
+//
+// errors that don't carry this information are bugs. At the boundary of every module, low-level errors should be
+// wrapped into a well-formed error of that module (see AtBoundary), so that the top of the stack can tell
+// well-formed errors - which are safe to show to the user - from bugs.
+type Error struct {
+	Inner         error
+	Message       string
+	Module        string
+	Stage         string
+	CorrelationID string
+	GoroutineID   uint64
+	StackTrace    string
+	Misc          map[string]any
+}
+
+func (e *Error) Error() string {
+	if e.Inner == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %v", e.Message, e.Inner)
+}
+
+// Unwrap makes the low-level error available to errors.Is and errors.As
+func (e *Error) Unwrap() error {
+	return e.Inner
+}
+
+// Wrap wraps err into a well-formed error with a user-facing message
+//
+// the stack trace and the ID of the calling goroutine are captured. If err (or an error it wraps) is a well-formed error,
+// its correlation ID is carried over, so the whole chain can be found in the logs with one ID.
+func Wrap(err error, messagef string, msgArgs ...any) *Error {
+	e := &Error{
+		Inner:       err,
+		Message:     fmt.Sprintf(messagef, msgArgs...),
+		GoroutineID: goroutineID(),
+		StackTrace:  string(debug.Stack()),
+		Misc:        make(map[string]any),
+	}
+
+	var inner *Error
+	if errors.As(err, &inner) {
+		e.CorrelationID = inner.CorrelationID
+	}
+	if e.CorrelationID == "" {
+		e.CorrelationID = NewCorrelationID()
+	}
+
+	return e
+}
+
+// AtBoundary converts err into a well-formed error of module
+//
+// errors that already belong to module are returned unchanged, so functions within a module can call AtBoundary
+// without wrapping the same error twice. A nil err returns nil.
+func AtBoundary(module string, err error, messagef string, msgArgs ...any) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) && e.Module == module {
+		return err
+	}
+
+	wrapped := Wrap(err, messagef, msgArgs...)
+	wrapped.Module = module
+
+	return wrapped
+}
+
+// WithStage records the pipeline stage the error occurred in
+func (e *Error) WithStage(stage string) *Error {
+	e.Stage = stage
+	return e
+}
+
+// WithCorrelationID replaces the generated correlation ID, e.g. with the ID of the request that failed
+func (e *Error) WithCorrelationID(id string) *Error {
+	e.CorrelationID = id
+	return e
+}
+
+// With attaches additional information to the error
+func (e *Error) With(key string, value any) *Error {
+	e.Misc[key] = value
+	return e
+}
+
+// UserMessage returns the message that is safe to show to the user
+//
+// for well-formed errors, this is the message of the outermost one. Any other error is a bug, so the user is only
+// told that something unexpected happened, along with an ID to look up the details in the logs.
+func UserMessage(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return fmt.Sprintf("%s [logID: %s]", e.Message, e.CorrelationID)
+	}
+	return "An unexpected error occurred; please file a bug."
+}
+
+// Log logs err with structured attributes for every piece of information a well-formed error carries
+//
+// errors that are not well-formed are logged as bugs, including the full error. A nil err is not logged.
+func Log(logger *slog.Logger, err error) {
+	if err == nil {
+		return
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		logger.Error("unexpected error", slog.String("error", err.Error()), slog.Bool("bug", true))
+		return
+	}
+
+	attrs := []any{
+		slog.String("error", err.Error()),
+		slog.String("module", e.Module),
+		slog.String("stage", e.Stage),
+		slog.String("correlation_id", e.CorrelationID),
+		slog.Uint64("goroutine", e.GoroutineID),
+		slog.String("stack", e.StackTrace),
+	}
+	if len(e.Misc) > 0 {
+		attrs = append(attrs, slog.Any("misc", e.Misc))
+	}
+
+	logger.Error(e.Message, attrs...)
+}
+
+// NewCorrelationID returns a random ID that ties a user-facing message to the logs
+func NewCorrelationID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// goroutineID parses the ID of the calling goroutine from the first line of its stack trace ("goroutine 42 [running]:")
+//
+// Go deliberately doesn't expose goroutine IDs; they are only meant to help correlate log lines, never to build logic on.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	b = b[:max(bytes.IndexByte(b, ' '), 0)]
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -1,10 +1,21 @@
 package error_handling
 
 import (
+	"concurrency-patterns/panic_recovery"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 )
 
+// checkStatusTimeout bounds every request made by checkStatus, so a stalled server can't hang the loop
+//
+// a timed out request is reported like any other error in the Result. The *Error itself has no Timeout method, but the
+// *url.Error it wraps does - just like pipeline.TimeoutError - so the consumer can tell timeouts apart from other failures
+// with errors.As and interface{ Timeout() bool }.
+const checkStatusTimeout = 10 * time.Second
+
 // here we create a type that income passes both the *http.Response and the error
 // possible from an iteration of the loop within a goroutine
 type Result struct {
@@ -12,18 +23,34 @@ type Result struct {
 	Response *http.Response
 }
 
+// CarryError returns a Result with err, so checkStatus delivers its panics (see panic_recovery.SendTo)
+func (r Result) CarryError(err error) any {
+	return Result{Error: err}
+}
+
 // checkStatus returns the channel that can be read from to retrieve results of an iteration of our loop
 func checkStatus(done <-chan any, urls ...string) <-chan Result {
+	client := &http.Client{Timeout: checkStatusTimeout}
+
+	return checkStatusWith(done, client.Get, urls...)
+}
+
+// checkStatusWith is checkStatus with the function that performs the request passed in,
+// so it can be wrapped - e.g. by a circuit breaker or a retry policy
+func checkStatusWith(done <-chan any, get func(url string) (*http.Response, error), urls ...string) <-chan Result {
 	results := make(chan Result)
+
 	go func() {
 		defer close(results)
+		defer panic_recovery.Recover("checkStatus", panic_recovery.SendTo(done, results))
 
 		for _, url := range urls {
 			var result Result
-			resp, err := http.Get(url)
+			resp, err := get(url)
 
-			// here we create, the result instance with the Error and Response fields set
-			result = Result{Error: err, Response: resp}
+			// here we create, the result instance with the Error and Response fields set.
+			// The low-level error of the http package is converted into a well-formed error at the boundary of this module
+			result = Result{Error: AtBoundary("checkStatus", err, "could not check the status of %s", url), Response: resp}
 
 			select {
 			case results <- result:
@@ -77,3 +104,22 @@ func errorHandlingExec_example2() {
 		fmt.Printf("Response: %v\n", result.Response.Status)
 	}
 }
+
+// errorHandlingExec_example3 shows how the top of the stack deals with well-formed errors
+//
+// the user only sees the friendly message and a log ID, while the logs contain everything needed to debug the error
+func errorHandlingExec_example3() {
+	done := make(chan any)
+	defer close(done)
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	for result := range checkStatus(done, "https://www.badass") {
+		if result.Error != nil {
+			Log(logger, result.Error)
+			fmt.Println(UserMessage(result.Error))
+			continue
+		}
+		fmt.Printf("Response: %v\n", result.Response.Status)
+	}
+}
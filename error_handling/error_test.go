@@ -0,0 +1,144 @@
+package error_handling_test
+
+import (
+	"bytes"
+	"concurrency-patterns/error_handling"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	inner := error_handling.Wrap(fs.ErrNotExist, "could not read %s", "config").WithCorrelationID("req-1")
+	outer := error_handling.Wrap(fmt.Errorf("loading: %w", inner), "could not start")
+
+	if outer.CorrelationID != "req-1" {
+		t.Errorf("got correlation ID %q, want the one of the inner error", outer.CorrelationID)
+	}
+	if got, want := outer.Error(), "could not start: loading: could not read config: file does not exist"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if outer.GoroutineID == 0 || !strings.Contains(outer.StackTrace, "TestWrap") {
+		t.Errorf("got goroutine %d and stack %q, want the caller's", outer.GoroutineID, outer.StackTrace)
+	}
+
+	if fresh := error_handling.Wrap(errors.New("low-level"), "failed"); fresh.CorrelationID == "" {
+		t.Errorf("got no correlation ID for an error without a well-formed inner error")
+	}
+}
+
+func TestAtBoundary(t *testing.T) {
+	if err := error_handling.AtBoundary("db", nil, "failed"); err != nil {
+		t.Errorf("got %v for a nil error, want nil", err)
+	}
+
+	err := error_handling.AtBoundary("db", fs.ErrNotExist, "could not query")
+
+	// within the module, the error is not wrapped twice
+	if again := error_handling.AtBoundary("db", fmt.Errorf("retrying: %w", err), "could not query again"); !errors.Is(again, err) || again.Error() != "retrying: "+err.Error() {
+		t.Errorf("got %v, want the error of the module unchanged", again)
+	}
+
+	// at the next boundary, it's wrapped into an error of that module, with the same correlation ID
+	var outer *error_handling.Error
+	if !errors.As(error_handling.AtBoundary("api", err, "could not serve"), &outer) {
+		t.Fatalf("got no well-formed error at the api boundary")
+	}
+	if outer.Module != "api" || outer.Inner != err || outer.CorrelationID != err.(*error_handling.Error).CorrelationID {
+		t.Errorf("got module %q, inner %v and correlation ID %q, want api, the db error and its correlation ID", outer.Module, outer.Inner, outer.CorrelationID)
+	}
+}
+
+func TestError_Unwrap(t *testing.T) {
+	err := error_handling.AtBoundary("api", error_handling.AtBoundary("db", fs.ErrNotExist, "could not query"), "could not serve")
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("errors.Is didn't find the low-level error in %v", err)
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		t.Errorf("errors.As found %v, which is not in the chain", pathErr)
+	}
+}
+
+func TestUserMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"well-formed", error_handling.Wrap(fs.ErrNotExist, "could not find %q", "report").WithCorrelationID("42"), `could not find "report" [logID: 42]`},
+		{"wrapped well-formed", fmt.Errorf("context: %w", error_handling.Wrap(nil, "busy").WithCorrelationID("7")), "busy [logID: 7]"},
+		{"bug", fs.ErrNotExist, "An unexpected error occurred; please file a bug."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := error_handling.UserMessage(tt.err); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLog(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want map[string]any
+	}{
+		{
+			"well-formed",
+			error_handling.AtBoundary("db", fs.ErrNotExist, "could not query").(*error_handling.Error).
+				WithStage("Load").WithCorrelationID("42").With("table", "orders"),
+			map[string]any{
+				"msg":            "could not query",
+				"error":          "could not query: file does not exist",
+				"module":         "db",
+				"stage":          "Load",
+				"correlation_id": "42",
+				"misc":           map[string]any{"table": "orders"},
+			},
+		},
+		{
+			"bug",
+			fs.ErrNotExist,
+			map[string]any{"msg": "unexpected error", "error": "file does not exist", "bug": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			error_handling.Log(slog.New(slog.NewJSONHandler(&buf, nil)), tt.err)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("could not decode the log line %q: %v", buf.String(), err)
+			}
+
+			for key, want := range tt.want {
+				if fmt.Sprint(got[key]) != fmt.Sprint(want) {
+					t.Errorf("got %s = %v, want %v", key, got[key], want)
+				}
+			}
+			if _, ok := got["stack"]; ok != (tt.name != "bug") {
+				t.Errorf("got a stack attribute: %v, want one only for well-formed errors", ok)
+			}
+		})
+	}
+}
+
+func TestLog_Nil(t *testing.T) {
+	var buf bytes.Buffer
+	error_handling.Log(slog.New(slog.NewJSONHandler(&buf, nil)), nil)
+
+	if buf.Len() != 0 {
+		t.Errorf("got %q, want nothing logged for a nil error", buf.String())
+	}
+}
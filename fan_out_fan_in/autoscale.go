@@ -0,0 +1,279 @@
+package fan_out_fan_in
+
+import (
+	"concurrency-patterns/clock"
+	ordone "concurrency-patterns/or_done_channel"
+	"concurrency-patterns/panic_recovery"
+	"concurrency-patterns/pipeline"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FanOutFanInExec fixes the number of prime finders at runtime.NumCPU() up front. For bursty workloads, a static
+// number of workers either wastes resources while it's quiet, or falls behind during a burst.
+//
+// AutoScale fans out to a number of workers that follows the load: values are queued in a backlog, and a controller
+// adds a worker whenever the backlog (or the time it takes to process a value) grows too large.
+// Workers that find no work for a while retire. The results of all workers are fanned in to a single stream.
+
+// ScaleConfig configures AutoScale; zero values are replaced by the defaults in brackets
+type ScaleConfig struct {
+	MinWorkers     int           // [1]
+	MaxWorkers     int           // [runtime.NumCPU()]
+	Backlog        int           // size of the queue in front of the workers [100]
+	ScaleUpBacklog int           // number of queued values that adds a worker [Backlog / 2]
+	ScaleUpLatency time.Duration // average time to process a value that adds a worker [not used]
+	CoolDown       time.Duration // minimum time between adding two workers [100ms]
+	IdleTimeout    time.Duration // time a worker waits for a value before it retires [1s]
+	Interval       time.Duration // how often the controller checks the load [10ms]
+	Clock          clock.Clock   // [clock.Real]
+}
+
+// ScaleStats reports the state of an AutoScale stage; it is safe to read while the stage is running
+type ScaleStats struct {
+	workers atomic.Int64
+	latency atomic.Int64 // moving average of the processing time in nanoseconds
+	backlog func() int
+}
+
+// Workers returns the number of running workers
+func (s *ScaleStats) Workers() int { return int(s.workers.Load()) }
+
+// Backlog returns the number of queued values
+func (s *ScaleStats) Backlog() int { return s.backlog() }
+
+// Latency returns the moving average of the time it takes to process a value
+func (s *ScaleStats) Latency() time.Duration { return time.Duration(s.latency.Load()) }
+
+// observe adds a processing time to the moving average, weighting it with 1/8
+func (s *ScaleStats) observe(d time.Duration) {
+	for {
+		old := s.latency.Load()
+		avg := old + (int64(d)-old)/8
+		if old == 0 {
+			avg = int64(d)
+		}
+		if s.latency.CompareAndSwap(old, avg) {
+			return
+		}
+	}
+}
+
+// retire decrements the number of workers, unless that would go below min
+func (s *ScaleStats) retire(min int) bool {
+	for {
+		n := s.workers.Load()
+		if n <= int64(min) {
+			return false
+		}
+		if s.workers.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
+
+func (cfg *ScaleConfig) defaults() {
+	cfg.MinWorkers = max(cfg.MinWorkers, 1)
+	if cfg.MaxWorkers <= 0 {
+		cfg.MaxWorkers = max(runtime.NumCPU(), cfg.MinWorkers)
+	}
+	cfg.MaxWorkers = max(cfg.MaxWorkers, cfg.MinWorkers)
+	if cfg.Backlog <= 0 {
+		cfg.Backlog = 100
+	}
+	if cfg.ScaleUpBacklog <= 0 {
+		cfg.ScaleUpBacklog = max(cfg.Backlog/2, 1)
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = 100 * time.Millisecond
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = time.Second
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Millisecond
+	}
+	cfg.Clock = clock.OrReal(cfg.Clock)
+}
+
+// AutoScale applies fn to every value of valueStream with a number of workers that follows the load
+//
+// the results are sent downstream in the order they are finished.
+func AutoScale(
+	done <-chan any,
+	valueStream <-chan any,
+	fn func(v any) any,
+	cfg ScaleConfig,
+) (<-chan any, *ScaleStats) {
+	cfg.defaults()
+	clk := cfg.Clock
+
+	backlog := make(chan any, cfg.Backlog)
+	resultStream := make(chan any)
+	stats := &ScaleStats{backlog: func() int { return len(backlog) }}
+
+	// the feeder moves the values into the backlog, where the controller can see how many are waiting
+	fed := make(chan any)
+	go func() {
+		defer close(fed)
+		defer close(backlog)
+		defer panic_recovery.Recover("AutoScale", panic_recovery.SendTo(done, resultStream))
+
+		for v := range ordone.OrDone(done, valueStream) {
+			select {
+			case <-done:
+				return
+			case backlog <- v:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var worker func()
+	worker = func() {
+		defer wg.Done()
+
+		// a worker that panicked in fn is replaced, so the backlog is still drained even at MaxWorkers;
+		// on every other exit, the worker takes itself off the count - unless it already did so to retire
+		retired, panicked := false, false
+		defer func() {
+			switch {
+			case retired:
+			case panicked:
+				wg.Add(1)
+				go worker()
+			default:
+				stats.workers.Add(-1)
+			}
+		}()
+		defer panic_recovery.Recover("AutoScale", func(*panic_recovery.PanicError) { panicked = true })
+
+		idle := clk.NewTimer(cfg.IdleTimeout)
+		defer idle.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-idle.C():
+				if retired = stats.retire(cfg.MinWorkers); retired {
+					return
+				}
+				idle.Reset(cfg.IdleTimeout)
+			case v, ok := <-backlog:
+				if !ok {
+					return
+				}
+
+				start := clk.Now()
+				result := fn(v)
+				stats.observe(clk.Since(start))
+
+				select {
+				case <-done:
+					return
+				case resultStream <- result:
+				}
+				idle.Reset(cfg.IdleTimeout)
+			}
+		}
+	}
+
+	spawn := func() {
+		stats.workers.Add(1)
+		wg.Add(1)
+		go worker()
+	}
+
+	for i := 0; i < cfg.MinWorkers; i++ {
+		spawn()
+	}
+
+	// the controller adds workers while values are fed; every wg.Add happens before it exits,
+	// so the closer below can safely wait for the workers afterwards
+	controlled := make(chan any)
+	go func() {
+		defer close(controlled)
+		defer panic_recovery.Recover("AutoScale", nil)
+
+		ticker := clk.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		var lastScaleUp time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case <-fed:
+				return
+			case <-ticker.C():
+			}
+
+			overloaded := stats.Backlog() >= cfg.ScaleUpBacklog ||
+				(cfg.ScaleUpLatency > 0 && stats.Latency() > cfg.ScaleUpLatency)
+
+			if overloaded && stats.Workers() < cfg.MaxWorkers && clk.Since(lastScaleUp) >= cfg.CoolDown {
+				spawn()
+				lastScaleUp = clk.Now()
+			}
+		}
+	}()
+
+	go func() {
+		<-controlled
+		wg.Wait()
+		close(resultStream)
+	}()
+
+	return resultStream, stats
+}
+
+// AutoScaleExec processes two bursts of values that take 10ms each, with a quiet period in between
+func AutoScaleExec() {
+	done := make(chan any)
+	defer close(done)
+
+	burst := func(n int) []int { return make([]int, n) }
+
+	valueStream := make(chan any)
+	go func() {
+		defer close(valueStream)
+		for _, n := range []int{200, 200} {
+			for v := range pipeline.Generator(done, burst(n)...) {
+				select {
+				case <-done:
+					return
+				case valueStream <- v:
+				}
+			}
+			time.Sleep(2 * time.Second)
+		}
+	}()
+
+	work := func(v any) any {
+		time.Sleep(10 * time.Millisecond)
+		return v
+	}
+
+	results, stats := AutoScale(done, valueStream, work, ScaleConfig{MaxWorkers: 16, IdleTimeout: 500 * time.Millisecond})
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	processed := 0
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				fmt.Printf("processed %d values\n", processed)
+				return
+			}
+			processed++
+		case <-ticker.C:
+			fmt.Printf("workers: %2d, backlog: %3d, latency: %v\n", stats.Workers(), stats.Backlog(), stats.Latency())
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package fan_out_fan_in_test
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/fan_out_fan_in"
+	"concurrency-patterns/panic_recovery"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// eventually advances clk by step until cond holds, giving the stage a moment to react after every step
+func eventually(t *testing.T, clk *clock.Fake, step time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		clk.Advance(step)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAutoScale(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	clk := clock.NewFake(time.Now())
+	cfg := fan_out_fan_in.ScaleConfig{
+		MinWorkers:     1,
+		MaxWorkers:     3,
+		Backlog:        10,
+		ScaleUpBacklog: 5,
+		CoolDown:       time.Second,
+		IdleTimeout:    time.Minute,
+		Interval:       100 * time.Millisecond,
+		Clock:          clk,
+	}
+
+	// the workers are stuck until release is closed, so the backlog fills up
+	release := make(chan any)
+	work := func(v any) any {
+		<-release
+		return v
+	}
+
+	// the upstream stays open until the end of the test, so the stage keeps running while it's idle
+	valueStream := make(chan any)
+	finish := make(chan any)
+	go func() {
+		defer close(valueStream)
+		for i := 0; i < 50; i++ {
+			valueStream <- i
+		}
+		<-finish
+	}()
+
+	results, stats := fan_out_fan_in.AutoScale(done, valueStream, work, cfg)
+
+	received := make(map[int]bool)
+	collected := make(chan any)
+	go func() {
+		defer close(collected)
+		for v := range results {
+			received[v.(int)] = true
+		}
+	}()
+
+	// the backlog is full, so the controller adds a worker at most once per cool-down, up to MaxWorkers
+	start := clk.Now()
+	eventually(t, clk, cfg.Interval, func() bool { return stats.Workers() == cfg.MaxWorkers })
+	if elapsed := clk.Since(start); elapsed < cfg.CoolDown {
+		t.Errorf("got %d workers after %v, want the scale-ups a cool-down apart", cfg.MaxWorkers, elapsed)
+	}
+
+	for i := 0; i < 30; i++ {
+		clk.Advance(cfg.Interval)
+		time.Sleep(time.Millisecond)
+		if n := stats.Workers(); n > cfg.MaxWorkers {
+			t.Fatalf("got %d workers, want at most %d", n, cfg.MaxWorkers)
+		}
+	}
+
+	// once the work is done, idle workers retire, but never below MinWorkers
+	close(release)
+	eventually(t, clk, cfg.IdleTimeout, func() bool { return stats.Backlog() == 0 && stats.Workers() == cfg.MinWorkers })
+
+	for i := 0; i < 5; i++ {
+		clk.Advance(cfg.IdleTimeout)
+		time.Sleep(time.Millisecond)
+	}
+	if n := stats.Workers(); n != cfg.MinWorkers {
+		t.Errorf("got %d workers while idle, want %d", n, cfg.MinWorkers)
+	}
+
+	close(finish)
+	<-collected
+	if len(received) != 50 {
+		t.Errorf("got %d distinct results, want 50", len(received))
+	}
+}
+
+func TestAutoScale_Panic(t *testing.T) {
+	panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+	defer panic_recovery.SetSupervisor(nil)
+
+	done := make(chan any)
+	defer close(done)
+
+	// a single worker, which panics on every third value; without a replacement, the rest would never be processed
+	cfg := fan_out_fan_in.ScaleConfig{MinWorkers: 1, MaxWorkers: 1, Backlog: 2, Clock: clock.NewFake(time.Now())}
+	work := func(v any) any {
+		if v.(int)%3 == 0 {
+			panic("boom")
+		}
+		return v
+	}
+
+	valueStream := make(chan any)
+	go func() {
+		defer close(valueStream)
+		for i := 1; i <= 10; i++ {
+			valueStream <- i
+		}
+	}()
+
+	results, stats := fan_out_fan_in.AutoScale(done, valueStream, work, cfg)
+
+	var got []int
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				if want := []int{1, 2, 4, 5, 7, 8, 10}; fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("got %v, want %v", got, want)
+				}
+				if n := stats.Workers(); n != 0 {
+					t.Errorf("got %d workers after the stream was closed, want 0", n)
+				}
+				return
+			}
+			got = append(got, r.(int))
+		case <-timeout:
+			t.Fatalf("the stream was not closed; got %v with %d workers", got, stats.Workers())
+		}
+	}
+}
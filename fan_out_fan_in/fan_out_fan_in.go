@@ -1,7 +1,10 @@
 package fan_out_fan_in
 
 import (
+	ordone "concurrency-patterns/or_done_channel"
+	"concurrency-patterns/panic_recovery"
 	"concurrency-patterns/pipeline"
+	"concurrency-patterns/semaphore"
 	"fmt"
 	mathRand "math/rand"
 	"runtime"
@@ -27,9 +30,45 @@ func exampleExec() {
 	fmt.Printf("Search took %v\n", time.Since(start))
 }
 
-func primeFinder(done chan any, stream <-chan int) <-chan any {
-	// todo implement a long running prime find procedure
-	return nil
+// primeFinder finds the primes in stream
+//
+// Katherine: it's a naive implementation on purpose - it checks every possible divisor - so that it takes a long time
+// and gives us a stage worth fanning out
+func primeFinder(done <-chan any, stream <-chan int) <-chan any {
+	primeStream := make(chan any)
+
+	go func() {
+		defer close(primeStream)
+		defer panic_recovery.Recover("primeFinder", panic_recovery.SendTo(done, primeStream))
+
+		for integer := range stream {
+			if !isPrime(integer) {
+				continue
+			}
+
+			select {
+			case <-done:
+				return
+			case primeStream <- integer:
+			}
+		}
+	}()
+
+	return primeStream
+}
+
+// isPrime checks every possible divisor of integer, which makes it slow for large integers
+func isPrime(integer int) bool {
+	if integer < 2 {
+		return false
+	}
+
+	for divisor := integer - 1; divisor > 1; divisor-- {
+		if integer%divisor == 0 {
+			return false
+		}
+	}
+	return true
 }
 
 func FanOutFanInExec() {
@@ -71,7 +110,7 @@ func FanOutFanInExec() {
 //
 // "fanning-in" means multiplexing or joining together multiple streams of data into a single stream
 func FanIn(
-	done chan any,
+	done <-chan any,
 	channels ...<-chan any,
 ) <-chan any {
 	var wg sync.WaitGroup
@@ -79,6 +118,7 @@ func FanIn(
 
 	multiplex := func(c <-chan any) {
 		defer wg.Done()
+		defer panic_recovery.Recover("FanIn", panic_recovery.SendTo(done, multiplexedStream))
 
 		for i := range c {
 			select {
@@ -101,4 +141,45 @@ func FanIn(
 	}()
 
 	return multiplexedStream
-}
\ No newline at end of file
+}
+
+// FanOutBounded starts a goroutine for every value of valueStream, but never runs more than limit of them at a time
+//
+// unlike fanning out to a fixed number of goroutines, the goroutines only exist while there is work for them.
+// The results are sent downstream in the order they are finished, not in the order of valueStream.
+func FanOutBounded(
+	done <-chan any,
+	valueStream <-chan any,
+	limit int,
+	fn func(v any) any,
+) <-chan any {
+	sem := semaphore.New(limit)
+	resultStream := make(chan any)
+
+	go func() {
+		var wg sync.WaitGroup
+		defer close(resultStream)
+		defer wg.Wait()
+		defer panic_recovery.Recover("FanOutBounded", panic_recovery.SendTo(done, resultStream))
+
+		for v := range ordone.OrDone(done, valueStream) {
+			if err := sem.Acquire(done); err != nil {
+				return
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer sem.Release()
+				defer panic_recovery.Recover("FanOutBounded", panic_recovery.SendTo(done, resultStream))
+
+				select {
+				case <-done:
+				case resultStream <- fn(v):
+				}
+			}()
+		}
+	}()
+
+	return resultStream
+}
@@ -0,0 +1,131 @@
+package fan_out_fan_in
+
+import (
+	"concurrency-patterns/pipeline"
+	"concurrency-patterns/work_stealing"
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanOutBounded(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	const limit = 3
+
+	valueStream := make(chan any, 10)
+	for i := range 10 {
+		valueStream <- i
+	}
+	close(valueStream)
+
+	// the first goroutines hold on to their slot until release is closed
+	var running, peak atomic.Int32
+	release := make(chan any)
+	results := FanOutBounded(done, valueStream, limit, func(v any) any {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+
+		<-release
+		return v.(int) * 2
+	})
+
+	for deadline := time.Now().Add(time.Second); running.Load() < limit; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d goroutines running, want %d", running.Load(), limit)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := running.Load(); got != limit {
+		t.Errorf("got %d goroutines running while the first ones were blocked, want %d", got, limit)
+	}
+	close(release)
+
+	sum := 0
+	for r := range results {
+		sum += r.(int)
+	}
+	if sum != 90 {
+		t.Errorf("got a sum of %d, want 90", sum)
+	}
+	if got := peak.Load(); got > limit {
+		t.Errorf("got %d goroutines running at once, want at most %d", got, limit)
+	}
+}
+
+// BenchmarkPrimeFinder compares fanning out the prime finder with running it on a work-stealing executor
+//
+// the cost of checking an integer is very uneven: composites are usually rejected after a few divisions,
+// primes take integer-2 of them.
+func BenchmarkPrimeFinder(b *testing.B) {
+	r := rand.New(rand.NewSource(42))
+	integers := make([]int, 500)
+	for i := range integers {
+		integers[i] = r.Intn(200_000)
+	}
+
+	b.Run("FanIn", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			done := make(chan any)
+
+			intStream := pipeline.Generator(done, integers...)
+			finders := make([]<-chan any, runtime.NumCPU())
+			for f := range finders {
+				finders[f] = primeFinder(done, intStream)
+			}
+
+			for range FanIn(done, finders...) {
+			}
+			close(done)
+		}
+	})
+
+	b.Run("WorkStealing", func(b *testing.B) {
+		done := make(chan any)
+		defer close(done)
+
+		ex := work_stealing.New(done, runtime.NumCPU())
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := ex.Submit(countPrimes(integers)).Get(done); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// countPrimes counts the primes in integers, forking the left half until only a few integers are left
+//
+// the halves are equally long, but not equally costly, so idle workers steal the halves that are still queued.
+func countPrimes(integers []int) work_stealing.Task {
+	return func(w *work_stealing.Worker) (any, error) {
+		if len(integers) <= 8 {
+			n := 0
+			for _, integer := range integers {
+				if isPrime(integer) {
+					n++
+				}
+			}
+			return n, nil
+		}
+
+		mid := len(integers) / 2
+		left := w.Fork(countPrimes(integers[:mid]))
+		right, err := countPrimes(integers[mid:])(w)
+		if err != nil {
+			return nil, err
+		}
+
+		l, err := w.Join(left)
+		if err != nil {
+			return nil, err
+		}
+		return l.(int) + right.(int), nil
+	}
+}
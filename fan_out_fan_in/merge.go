@@ -0,0 +1,125 @@
+package fan_out_fan_in
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"container/heap"
+	"fmt"
+)
+
+// when every input of a fan-in is already sorted - e.g. the per-shard timestamps of a partitioned log - FanIn loses that order.
+// MergeSorted keeps it: it holds the next value of every input in a min-heap, sends the smallest one,
+// and replaces it with the next value of the same input (a k-way merge).
+//
+// to know which value is the smallest, MergeSorted has to wait until every open input has either sent a value or been
+// closed. A slow input therefore holds back the whole merge - that's the price of a globally ordered stream.
+
+// MergeSorted merges streams that are each sorted according to less into a single sorted stream
+func MergeSorted[T any](
+	done <-chan any,
+	less func(a, b T) bool,
+	streams ...<-chan T,
+) <-chan T {
+	mergedStream := make(chan T)
+
+	go func() {
+		defer close(mergedStream)
+		defer panic_recovery.Recover("MergeSorted", panic_recovery.SendTo(done, mergedStream))
+
+		// next receives the next value of streams[i] and pushes it to the heap, unless the stream is closed
+		h := &mergeHeap[T]{less: less}
+		next := func(i int) bool {
+			select {
+			case <-done:
+				return false
+			case v, ok := <-streams[i]:
+				if ok {
+					heap.Push(h, mergeItem[T]{value: v, stream: i})
+				}
+				return true
+			}
+		}
+
+		for i := range streams {
+			if !next(i) {
+				return
+			}
+		}
+
+		for h.Len() > 0 {
+			item := heap.Pop(h).(mergeItem[T])
+
+			select {
+			case <-done:
+				return
+			case mergedStream <- item.value:
+			}
+
+			if !next(item.stream) {
+				return
+			}
+		}
+	}()
+
+	return mergedStream
+}
+
+type mergeItem[T any] struct {
+	value  T
+	stream int
+}
+
+// mergeHeap implements heap.Interface; ties are broken by the index of the stream, so the merge is stable
+type mergeHeap[T any] struct {
+	items []mergeItem[T]
+	less  func(a, b T) bool
+}
+
+func (h *mergeHeap[T]) Len() int { return len(h.items) }
+
+func (h *mergeHeap[T]) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.value, b.value) {
+		return true
+	}
+	if h.less(b.value, a.value) {
+		return false
+	}
+	return a.stream < b.stream
+}
+
+func (h *mergeHeap[T]) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap[T]) Push(x any) { h.items = append(h.items, x.(mergeItem[T])) }
+
+func (h *mergeHeap[T]) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// MergeSortedExec merges three sorted shards of a log
+func MergeSortedExec() {
+	done := make(chan any)
+	defer close(done)
+
+	shard := func(timestamps ...int) <-chan int {
+		stream := make(chan int)
+		go func() {
+			defer close(stream)
+			for _, ts := range timestamps {
+				select {
+				case <-done:
+					return
+				case stream <- ts:
+				}
+			}
+		}()
+		return stream
+	}
+
+	less := func(a, b int) bool { return a < b }
+
+	for ts := range MergeSorted(done, less, shard(1, 4, 9), shard(2, 3, 10, 11), shard(5)) {
+		fmt.Println(ts)
+	}
+}
@@ -0,0 +1,87 @@
+package fan_out_fan_in_test
+
+import (
+	"concurrency-patterns/fan_out_fan_in"
+	"fmt"
+	"testing"
+)
+
+// sorted returns a closed stream holding values
+func sorted[T any](values ...T) <-chan T {
+	stream := make(chan T, len(values))
+	for _, v := range values {
+		stream <- v
+	}
+	close(stream)
+	return stream
+}
+
+func TestMergeSorted(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+
+	tests := []struct {
+		name    string
+		streams []<-chan int
+		want    string
+	}{
+		{"no inputs", nil, "[]"},
+		{"empty inputs", []<-chan int{sorted[int](), sorted[int]()}, "[]"},
+		{"inputs closing at different times", []<-chan int{sorted(1, 4, 9), sorted(2, 3, 10, 11), sorted(5), sorted[int]()}, "[1 2 3 4 5 9 10 11]"},
+		{"duplicates", []<-chan int{sorted(1, 1, 2), sorted(1, 2)}, "[1 1 1 2 2]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			var got []int
+			for v := range fan_out_fan_in.MergeSorted(done, less, tt.streams...) {
+				got = append(got, v)
+			}
+
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSorted_StableTies(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	type entry struct {
+		ts     int
+		source string
+	}
+	less := func(a, b entry) bool { return a.ts < b.ts }
+
+	// entries with the same timestamp are sent in the order of their inputs
+	merged := fan_out_fan_in.MergeSorted(done, less,
+		sorted(entry{1, "a"}, entry{2, "a"}),
+		sorted(entry{1, "b"}, entry{2, "b"}),
+		sorted(entry{1, "c"}),
+	)
+
+	var got string
+	for e := range merged {
+		got += fmt.Sprintf("%d%s ", e.ts, e.source)
+	}
+	if want := "1a 1b 1c 2a 2b "; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestMergeSorted_Cancel(t *testing.T) {
+	done := make(chan any)
+
+	// the second input never sends, so the merge can't tell whether 1 is the smallest value until done is closed
+	never := make(chan int)
+	merged := fan_out_fan_in.MergeSorted(done, func(a, b int) bool { return a < b }, sorted(1, 2), never)
+
+	close(done)
+	for v := range merged {
+		t.Errorf("got %d, want no values from a canceled merge", v)
+	}
+}
@@ -0,0 +1,212 @@
+package fan_out_fan_in
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"sort"
+)
+
+// FanIn treats all its input channels equally: whichever multiplexing goroutine wins the race sends the next value.
+// When control messages and bulk data are merged into one stream, that means control messages queue behind data.
+//
+// the priority-aware variants below read one value ahead from every input (the head), and decide which head to send next:
+// 1. PriorityFanIn always sends the head of the input with the highest priority (strict priority)
+// 2. WeightedFanIn sends the heads in proportion to the weights of their inputs (weighted fair queuing)
+//
+// with strict priority, a busy high-priority input can starve the others forever. PriorityFanIn therefore counts how often
+// an input with a waiting head was passed over, and once that reaches maxStarvation, it sends that head next.
+
+// PrioritizedStream is an input of PriorityFanIn or WeightedFanIn
+type PrioritizedStream struct {
+	Stream   <-chan any
+	Priority int // PriorityFanIn: inputs with a higher priority are drained first
+	Weight   int // WeightedFanIn: share of the output; values below 1 count as 1
+}
+
+// lane is an input as seen by the merging goroutine
+type lane struct {
+	PrioritizedStream
+	heads   chan any // holds the value read ahead from the input
+	head    any
+	hasHead bool
+	closed  bool
+
+	skipped int // PriorityFanIn: times the head was passed over
+	current int // WeightedFanIn: smooth weighted round robin counter
+}
+
+// PriorityFanIn merges streams, preferring the inputs with the highest priority
+//
+// an input whose head was passed over maxStarvation times in a row is served next, no matter its priority;
+// 0 disables starvation protection. Inputs with the same priority are served in the order they were passed in.
+func PriorityFanIn(
+	done <-chan any,
+	maxStarvation int,
+	streams ...PrioritizedStream,
+) <-chan any {
+	pick := func(ready []*lane) *lane {
+		next := ready[0] // ready is sorted by priority
+		if maxStarvation > 0 {
+			for _, l := range ready {
+				if l.skipped >= maxStarvation && l.skipped > next.skipped {
+					next = l
+				}
+			}
+		}
+		return next
+	}
+
+	served := func(ready []*lane, next *lane) {
+		for _, l := range ready {
+			l.skipped++
+		}
+		next.skipped = 0
+	}
+
+	return mergeLanes(done, "PriorityFanIn", streams, pick, served)
+}
+
+// WeightedFanIn merges streams, sending values in proportion to the weights of their inputs
+//
+// it uses smooth weighted round robin: every input with a waiting head earns its weight, the input with the most credit
+// is served and pays the total weight of all waiting inputs. An input with weight 3 next to one with weight 1 is therefore
+// served 3 out of 4 times, interleaved rather than in bursts - and every input is served eventually.
+func WeightedFanIn(
+	done <-chan any,
+	streams ...PrioritizedStream,
+) <-chan any {
+	pick := func(ready []*lane) *lane {
+		var next *lane
+		for _, l := range ready {
+			if next == nil || l.current+max(l.Weight, 1) > next.current+max(next.Weight, 1) {
+				next = l
+			}
+		}
+		return next
+	}
+
+	served := func(ready []*lane, next *lane) {
+		total := 0
+		for _, l := range ready {
+			l.current += max(l.Weight, 1)
+			total += max(l.Weight, 1)
+		}
+		next.current -= total
+	}
+
+	return mergeLanes(done, "WeightedFanIn", streams, pick, served)
+}
+
+// mergeLanes reads ahead one value from every stream, and lets pick choose which of the waiting heads is sent next
+//
+// while the chosen head waits for the consumer, a head that arrives on another lane makes mergeLanes pick again -
+// so a control message doesn't queue behind a data value that was chosen before it arrived. pick must therefore not
+// change the lanes; served updates them once the head was actually sent.
+func mergeLanes(
+	done <-chan any,
+	stage string,
+	streams []PrioritizedStream,
+	pick func(ready []*lane) *lane,
+	served func(ready []*lane, next *lane),
+) <-chan any {
+	mergedStream := make(chan any)
+	notify := make(chan struct{}, 1) // signals the merging goroutine that a head arrived
+
+	lanes := make([]*lane, len(streams))
+	for i, s := range streams {
+		lanes[i] = &lane{PrioritizedStream: s, heads: make(chan any, 1)}
+	}
+	sort.SliceStable(lanes, func(i, j int) bool { return lanes[i].Priority > lanes[j].Priority })
+
+	// every input gets a goroutine that reads ahead into its lane
+	for _, l := range lanes {
+		go func() {
+			defer func() {
+				close(l.heads)
+				select {
+				case notify <- struct{}{}:
+				default:
+				}
+			}()
+			defer panic_recovery.Recover(stage, nil)
+
+			for {
+				select {
+				case <-done:
+					return
+				case v, ok := <-l.Stream:
+					if !ok {
+						return
+					}
+
+					select {
+					case <-done:
+						return
+					case l.heads <- v:
+					}
+
+					select {
+					case notify <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(mergedStream)
+		defer panic_recovery.Recover(stage, panic_recovery.SendTo(done, mergedStream))
+
+		ready := make([]*lane, 0, len(lanes))
+		for {
+			ready = ready[:0]
+			open := 0
+
+			for _, l := range lanes {
+				if !l.hasHead && !l.closed {
+					select {
+					case v, ok := <-l.heads:
+						if ok {
+							l.head, l.hasHead = v, true
+						} else {
+							l.closed = true
+						}
+					default:
+					}
+				}
+
+				if l.hasHead {
+					ready = append(ready, l)
+				}
+				if !l.closed || l.hasHead {
+					open++
+				}
+			}
+
+			if open == 0 {
+				return
+			}
+
+			if len(ready) == 0 {
+				select {
+				case <-done:
+					return
+				case <-notify:
+				}
+				continue
+			}
+
+			next := pick(ready)
+			select {
+			case <-done:
+				return
+			case <-notify:
+			case mergedStream <- next.head:
+				served(ready, next)
+				next.head, next.hasHead = nil, false
+			}
+		}
+	}()
+
+	return mergedStream
+}
@@ -0,0 +1,138 @@
+package fan_out_fan_in_test
+
+import (
+	"concurrency-patterns/fan_out_fan_in"
+	"strings"
+	"testing"
+	"time"
+)
+
+// filled returns a closed stream holding n copies of v
+func filled(v string, n int) <-chan any {
+	stream := make(chan any, n)
+	for i := 0; i < n; i++ {
+		stream <- v
+	}
+	close(stream)
+	return stream
+}
+
+// readPaced reads n values, pausing before every read, so all inputs have a value waiting when the merge decides
+func readPaced(stream <-chan any, n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		time.Sleep(2 * time.Millisecond)
+		sb.WriteString((<-stream).(string))
+	}
+	return sb.String()
+}
+
+func TestPriorityFanIn(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxStarvation int
+		want          func(got string) bool
+	}{
+		// the merge may pick its first value before both inputs have one waiting, so it is left out
+		{"strict priority", 0, func(got string) bool { return got[1:] == strings.Repeat("h", 11) }},
+		{
+			// once both inputs have values waiting, the low-priority input is served after every second high-priority value
+			"starvation protection", 2, func(got string) bool {
+				first := strings.Index(got, "l")
+				return first >= 0 && first <= 3 && got[first:first+9] == "lhhlhhlhh"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			merged := fan_out_fan_in.PriorityFanIn(done, tt.maxStarvation,
+				fan_out_fan_in.PrioritizedStream{Stream: filled("l", 20), Priority: 1},
+				fan_out_fan_in.PrioritizedStream{Stream: filled("h", 20), Priority: 2},
+			)
+
+			if got := readPaced(merged, 12); !tt.want(got) {
+				t.Errorf("got %s", got)
+			}
+		})
+	}
+}
+
+func TestWeightedFanIn(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	merged := fan_out_fan_in.WeightedFanIn(done,
+		fan_out_fan_in.PrioritizedStream{Stream: filled("a", 20), Weight: 3},
+		fan_out_fan_in.PrioritizedStream{Stream: filled("b", 20), Weight: 1},
+	)
+
+	// smooth weighted round robin interleaves the values instead of sending them in bursts: every 4 values hold one b.
+	// The merge may pick its first value before both inputs have one waiting, so it is left out.
+	got := readPaced(merged, 13)
+	for i := 1; i+4 <= len(got); i++ {
+		if strings.Count(got[i:i+4], "b") != 1 {
+			t.Fatalf("got %s, want one b in every 4 values", got)
+		}
+	}
+}
+
+func TestPriorityFanIn_Close(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	// the inputs close at different times; once the high-priority input is drained, the others take over
+	merged := fan_out_fan_in.PriorityFanIn(done, 0,
+		fan_out_fan_in.PrioritizedStream{Stream: filled("l", 5), Priority: 1},
+		fan_out_fan_in.PrioritizedStream{Stream: filled("h", 3), Priority: 2},
+		fan_out_fan_in.PrioritizedStream{Stream: filled("e", 0), Priority: 3},
+	)
+
+	counts := make(map[string]int)
+	for v := range merged {
+		counts[v.(string)]++
+	}
+	if counts["h"] != 3 || counts["l"] != 5 || len(counts) != 2 {
+		t.Errorf("got %v, want every value of every input", counts)
+	}
+}
+
+func TestWeightedFanIn_Cancel(t *testing.T) {
+	done := make(chan any)
+
+	// the inputs never close, so only done ends the merge
+	never := make(chan any)
+	merged := fan_out_fan_in.WeightedFanIn(done,
+		fan_out_fan_in.PrioritizedStream{Stream: never},
+		fan_out_fan_in.PrioritizedStream{Stream: filled("a", 1)},
+	)
+
+	<-merged
+	close(done)
+
+	for range merged {
+	}
+}
+
+func TestPriorityFanIn_LateControl(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	control := make(chan any)
+	merged := fan_out_fan_in.PriorityFanIn(done, 0,
+		fan_out_fan_in.PrioritizedStream{Stream: filled("data", 3), Priority: 1},
+		fan_out_fan_in.PrioritizedStream{Stream: control, Priority: 2},
+	)
+
+	// nobody reads yet, so the merge waits with a data head it already chose; then a control message arrives
+	time.Sleep(5 * time.Millisecond)
+	control <- "control"
+	time.Sleep(5 * time.Millisecond)
+
+	if got := <-merged; got != "control" {
+		t.Errorf("got %v first, want the control message that arrived while data was waiting for the consumer", got)
+	}
+}
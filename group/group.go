@@ -0,0 +1,221 @@
+package group
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"concurrency-patterns/pipeline"
+	"concurrency-patterns/semaphore"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Group is a structured way to run a set of goroutines that work on subtasks of a common task
+//
+// it takes care of the bookkeeping we'd otherwise write by hand for every set of goroutines:
+// wg.Add and wg.Done, remembering the first error, telling the siblings to stop when one of them fails,
+// limiting how many run at once, and recovering their panics.
+//
+// every goroutine receives the group's done channel, which has the same signature as the done channel of the pipeline
+// stages - so a goroutine of the group can build a pipeline on it, and the whole pipeline is torn down when a sibling fails.
+type Group struct {
+	parent     <-chan any
+	cancel     chan any // the group's done channel
+	cancelOnce sync.Once
+
+	wg  sync.WaitGroup
+	sem *semaphore.Semaphore
+
+	mu         sync.Mutex
+	collectAll bool
+	errs       []error
+	running    int
+	idle       chan any // closed once the last running goroutine returned, so the watcher of parent stops
+}
+
+// ErrCanceled is recorded for a function passed to Go that was not started, because the group was done
+var ErrCanceled = errors.New("group: canceled before the goroutine was started")
+
+// New returns a Group whose done channel is closed when parent is closed, or when one of its goroutines fails
+//
+// parent is only watched while goroutines of the group are running, so a group that is abandoned without a call to Wait
+// doesn't leave a goroutine behind.
+func New(parent <-chan any) *Group {
+	return &Group{parent: parent, cancel: make(chan any)}
+}
+
+// Done returns the done channel shared by the goroutines of the group
+//
+// if parent is closed while no goroutine of the group is running, the channel is closed by the next call to Go or Done.
+func (g *Group) Done() <-chan any {
+	g.watchParent()
+	return g.cancel
+}
+
+// SetLimit limits the number of goroutines running at once to n; it must be called before the first call to Go
+func (g *Group) SetLimit(n int) {
+	g.sem = semaphore.New(n)
+}
+
+// CollectAll makes Wait return all errors joined with errors.Join, instead of only the first one
+//
+// the siblings are still told to stop on the first error, so the other errors are typically the ones of goroutines
+// that were already failing at the time.
+func (g *Group) CollectAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.collectAll = true
+}
+
+// Go runs fn in a new goroutine of the group
+//
+// if the group is limited, Go blocks until a running goroutine has returned. If the group is done in the meantime,
+// fn is not started at all, and ErrCanceled is recorded as its error. A panic in fn is recovered and treated like
+// an error returned by fn.
+func (g *Group) Go(fn func(done <-chan any) error) {
+	if g.sem != nil {
+		if err := g.sem.Acquire(g.Done()); err != nil {
+			g.fail(ErrCanceled)
+			return
+		}
+	}
+
+	g.start(fn)
+}
+
+// TryGo runs fn in a new goroutine of the group, unless the group is limited and the limit is reached
+func (g *Group) TryGo(fn func(done <-chan any) error) bool {
+	if g.sem != nil && !g.sem.TryAcquire() {
+		return false
+	}
+
+	g.start(fn)
+	return true
+}
+
+func (g *Group) start(fn func(done <-chan any) error) {
+	g.wg.Add(1)
+	g.enter()
+
+	go func() {
+		defer g.wg.Done()
+		defer g.leave()
+		if g.sem != nil {
+			defer g.sem.Release()
+		}
+		defer panic_recovery.Recover("group.Go", func(err *panic_recovery.PanicError) { g.fail(err) })
+
+		if err := fn(g.cancel); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// enter counts a running goroutine; the first one starts a watcher that closes the done channel when parent is closed
+func (g *Group) enter() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.running == 0 {
+		g.idle = make(chan any)
+		go g.watch(g.idle)
+	}
+	g.running++
+}
+
+// leave stops the watcher once the last running goroutine returned
+func (g *Group) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.running--
+	if g.running == 0 {
+		close(g.idle)
+	}
+}
+
+func (g *Group) watch(idle <-chan any) {
+	select {
+	case <-g.parent:
+		g.stop()
+	case <-g.cancel:
+	case <-idle:
+	}
+}
+
+// watchParent closes the done channel if parent was closed while no goroutine was running
+func (g *Group) watchParent() {
+	select {
+	case <-g.parent:
+		g.stop()
+	default:
+	}
+}
+
+func (g *Group) stop() {
+	g.cancelOnce.Do(func() { close(g.cancel) })
+}
+
+// fail records err and tells the siblings to stop
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	if len(g.errs) == 0 || g.collectAll {
+		g.errs = append(g.errs, err)
+	}
+	g.mu.Unlock()
+
+	g.stop()
+}
+
+// Wait blocks until all goroutines of the group have returned, and returns the first error (or all of them, see CollectAll)
+//
+// the done channel of the group is closed once Wait returns.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.stop()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.collectAll {
+		return errors.Join(g.errs...)
+	}
+	if len(g.errs) > 0 {
+		return g.errs[0]
+	}
+	return nil
+}
+
+// GroupExec runs three pipelines in a group; once one of them fails, the other two are torn down
+func GroupExec() {
+	done := make(chan any)
+	defer close(done)
+
+	g := New(done)
+	g.SetLimit(2)
+
+	for i := 1; i <= 3; i++ {
+		g.Go(func(done <-chan any) error {
+			sum := 0
+			for v := range pipeline.Take(done, pipeline.Repeat(done, i), 1_000_000) {
+				sum += v.(int)
+				if i == 2 && sum > 1000 {
+					return fmt.Errorf("pipeline %d: sum exceeded 1000", i)
+				}
+			}
+
+			// the pipeline also ends early if the group is done, so check why it ended
+			select {
+			case <-done:
+				fmt.Printf("pipeline %d: cancelled\n", i)
+			default:
+				fmt.Printf("pipeline %d: sum %d\n", i, sum)
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+}
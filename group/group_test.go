@@ -0,0 +1,183 @@
+package group_test
+
+import (
+	"concurrency-patterns/group"
+	"concurrency-patterns/panic_recovery"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+var errFailed = errors.New("failed")
+
+func TestGroup_FirstErrorStopsSiblings(t *testing.T) {
+	g := group.New(nil)
+
+	stopped := make(chan any)
+	g.Go(func(done <-chan any) error {
+		<-done
+		close(stopped)
+		return nil
+	})
+	g.Go(func(done <-chan any) error { return errFailed })
+
+	<-stopped
+	if err := g.Wait(); !errors.Is(err, errFailed) {
+		t.Errorf("got %v, want the error of the failed goroutine", err)
+	}
+}
+
+func TestGroup_Wait(t *testing.T) {
+	g := group.New(nil)
+	for i := 0; i < 3; i++ {
+		g.Go(func(done <-chan any) error { return nil })
+	}
+
+	if err := g.Wait(); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+
+	// Wait closes the done channel; the test hangs if it doesn't
+	<-g.Done()
+}
+
+func TestGroup_Limit(t *testing.T) {
+	g := group.New(nil)
+	g.SetLimit(1)
+
+	started, release := make(chan any), make(chan any)
+	g.Go(func(done <-chan any) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	if g.TryGo(func(done <-chan any) error { return nil }) {
+		t.Error("got TryGo to start a goroutine beyond the limit")
+	}
+
+	// Go blocks until the running goroutine returns
+	second := make(chan any)
+	go g.Go(func(done <-chan any) error {
+		close(second)
+		return nil
+	})
+
+	select {
+	case <-second:
+		t.Fatal("got a second goroutine running beyond the limit")
+	default:
+	}
+	close(release)
+	<-second
+
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !g.TryGo(func(done <-chan any) error { return nil }) {
+		t.Error("got TryGo to refuse a goroutine below the limit")
+	}
+}
+
+func TestGroup_CollectAll(t *testing.T) {
+	g := group.New(nil)
+	g.CollectAll()
+
+	errOther := errors.New("other")
+
+	// both goroutines fail only after both have started, so neither is stopped by the other one
+	barrier := make(chan any)
+	started := make(chan any, 2)
+	for _, err := range []error{errFailed, errOther} {
+		g.Go(func(done <-chan any) error {
+			started <- struct{}{}
+			<-barrier
+			return err
+		})
+	}
+	<-started
+	<-started
+	close(barrier)
+
+	err := g.Wait()
+	if !errors.Is(err, errFailed) || !errors.Is(err, errOther) {
+		t.Errorf("got %v, want both errors", err)
+	}
+}
+
+func TestGroup_Panic(t *testing.T) {
+	panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+	defer panic_recovery.SetSupervisor(nil)
+
+	g := group.New(nil)
+	g.Go(func(done <-chan any) error { panic("boom") })
+
+	var panicErr *panic_recovery.PanicError
+	if err := g.Wait(); !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Errorf("got %v, want the recovered panic", err)
+	}
+}
+
+func TestGroup_ParentDone(t *testing.T) {
+	parent := make(chan any)
+	g := group.New(parent)
+
+	g.Go(func(done <-chan any) error {
+		<-done
+		return nil
+	})
+	close(parent)
+
+	if err := g.Wait(); err != nil {
+		t.Errorf("got %v, want nil: a canceled parent is no error of the group", err)
+	}
+}
+
+func TestGroup_GoAfterDone(t *testing.T) {
+	parent := make(chan any)
+	g := group.New(parent)
+	g.SetLimit(1)
+
+	// the only slot is taken until the group is done, so the second function is never started
+	g.Go(func(done <-chan any) error {
+		<-done
+		return nil
+	})
+
+	started := false
+	returned := make(chan any)
+	go func() {
+		defer close(returned)
+		g.Go(func(done <-chan any) error {
+			started = true
+			return nil
+		})
+	}()
+	close(parent)
+	<-returned
+
+	if err := g.Wait(); !errors.Is(err, group.ErrCanceled) || started {
+		t.Errorf("got %v, started %v, want ErrCanceled for the function that was not started", err, started)
+	}
+}
+
+func TestGroup_AbandonedDoesNotLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// the group is never waited for, and its parent is never closed
+	returned := make(chan any)
+	g := group.New(make(chan any))
+	g.Go(func(done <-chan any) error {
+		defer close(returned)
+		return nil
+	})
+	<-returned
+
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d goroutines, want %d after the group's goroutines returned", runtime.NumGoroutine(), before)
+		}
+	}
+}
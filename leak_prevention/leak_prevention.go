@@ -1,6 +1,7 @@
 package leak_prevention
 
 import (
+	"concurrency-patterns/clock"
 	"fmt"
 	"math/rand"
 	"time"
@@ -33,12 +34,17 @@ func doWork(
 }
 
 func leakPreventionExec_blockedOnAttemptingToWrite() {
+	blockedOnAttemptingToWrite(clock.Real{})
+}
+
+// blockedOnAttemptingToWrite takes the clock as parameter, so a test can advance it instead of sleeping
+func blockedOnAttemptingToWrite(clk clock.Clock) {
 	done := make(chan any)
 	terminated := doWork(done, nil)
 
 	go func() {
 		// cancel the operation after 1 second
-		time.Sleep(1 * time.Second)
+		clk.Sleep(1 * time.Second)
 		fmt.Println("cancelling do work goroutine...")
 
 		// when closing the done channel, the goroutine in the doWork function is cancelled
@@ -74,6 +80,11 @@ func newRandStream(
 }
 
 func leakPreventionExec_blockedOnAttemptingToRead() {
+	blockedOnAttemptingToRead(clock.Real{})
+}
+
+// blockedOnAttemptingToRead takes the clock as parameter, so a test can advance it instead of sleeping
+func blockedOnAttemptingToRead(clk clock.Clock) {
 	done := make(chan any)
 	randStream := newRandStream(done)
 
@@ -87,5 +98,5 @@ func leakPreventionExec_blockedOnAttemptingToRead() {
 	close(done)
 
 	// simulate ongoing work
-	time.Sleep(1 * time.Second)
+	clk.Sleep(1 * time.Second)
 }
@@ -0,0 +1,52 @@
+package leak_prevention
+
+import (
+	"concurrency-patterns/clock"
+	"testing"
+	"time"
+)
+
+// returned runs fn in a goroutine, and returns a channel that is closed once fn has returned
+func returned(fn func()) <-chan any {
+	c := make(chan any)
+	go func() {
+		defer close(c)
+		fn()
+	}()
+	return c
+}
+
+func TestBlockedOnAttemptingToWrite(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+
+	// doWork only terminates once the goroutine sleeping on the clock closed done
+	finished := returned(func() { blockedOnAttemptingToWrite(clk) })
+	clk.BlockUntil(1)
+
+	select {
+	case <-finished:
+		t.Fatalf("returned before the second passed")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clk.Advance(time.Second)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("did not return after the second passed")
+	}
+}
+
+func TestBlockedOnAttemptingToRead(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+
+	finished := returned(func() { blockedOnAttemptingToRead(clk) })
+	clk.BlockUntil(1)
+
+	clk.Advance(time.Second)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("did not return after the second passed")
+	}
+}
@@ -1,14 +1,16 @@
 package or_channel
 
 import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/panic_recovery"
 	"fmt"
 	"time"
 )
 
-//var or = func(channels ...<-chan any) <-chan any
+//var Or = func(channels ...<-chan any) <-chan any
 
-// or takes a variadic slice of channels and returns a single channel
-func or(channels ...<-chan any) <-chan any {
+// Or takes a variadic slice of channels and returns a single channel
+func Or(channels ...<-chan any) <-chan any {
 
 	switch len(channels) {
 
@@ -28,6 +30,7 @@ func or(channels ...<-chan any) <-chan any {
 	// main body of the function where recursion happens
 	go func() {
 		defer close(orDone)
+		defer panic_recovery.Recover("Or", nil)
 
 		switch len(channels) {
 
@@ -49,7 +52,7 @@ func or(channels ...<-chan any) <-chan any {
 				// this recurrence relation will be structure the rest of the slice into or-channels for a tree from which the first signal will return.
 				//
 				// we also pass in the orDone channel so that when the goroutines up the tree exit, goroutines down the tree also exit
-			case <-or(append(channels[3:], orDone)...):
+			case <-Or(append(channels[3:], orDone)...):
 			}
 		}
 	}()
@@ -57,24 +60,31 @@ func or(channels ...<-chan any) <-chan any {
 	return orDone
 }
 
+// Signal returns a channel that is closed once after has passed on clk
+func Signal(clk clock.Clock, after time.Duration) <-chan any {
+	c := make(chan any)
+
+	go func() {
+		defer close(c)
+		defer panic_recovery.Recover("Signal", nil)
+		clk.Sleep(after)
+	}()
+	return c
+}
+
 // orChannelExec is a brief example that takes channels that close after a set duration and uses the or function to combine these into a single channel that closes:
 //
 // notice that despite placing several generals in our call to or that takes various times to close,
 // our channel that closes after one second causes the entire channel created by a call to or to close
 // this is because - despite its place in a tree the or function builds - it will always close first, and thus the channels that depend on its closure will close as well
 func orChannelExec() {
+	clk := clock.Real{}
 	sig := func(after time.Duration) <-chan any {
-		c := make(chan any)
-
-		go func() {
-			defer close(c)
-			time.Sleep(after)
-		}()
-		return c
+		return Signal(clk, after)
 	}
 
-	start := time.Now()
-	<-or(
+	start := clk.Now()
+	<-Or(
 		sig(2*time.Hour),
 		sig(5*time.Minute),
 		sig(1*time.Second),
@@ -82,5 +92,5 @@ func orChannelExec() {
 		sig(1*time.Minute),
 	)
 
-	fmt.Printf("done after %v", time.Since(start))
+	fmt.Printf("done after %v", clk.Since(start))
 }
@@ -0,0 +1,54 @@
+package or_channel_test
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/or_channel"
+	"testing"
+	"time"
+)
+
+func TestOr_ClosesWithFirstSignal(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	start := clk.Now()
+
+	durations := []time.Duration{2 * time.Hour, 5 * time.Minute, 1 * time.Second, 1 * time.Hour, 1 * time.Minute}
+	signals := make([]<-chan any, len(durations))
+	for i, d := range durations {
+		signals[i] = or_channel.Signal(clk, d)
+	}
+	orDone := or_channel.Or(signals...)
+
+	// release the remaining signal goroutines at the end
+	defer clk.Advance(2 * time.Hour)
+
+	clk.BlockUntil(len(durations))
+
+	clk.Advance(999 * time.Millisecond)
+	select {
+	case <-orDone:
+		t.Fatalf("or-channel closed before any signal")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clk.Advance(time.Millisecond)
+	select {
+	case <-orDone:
+	case <-time.After(time.Second):
+		t.Fatalf("or-channel did not close after the 1 second signal")
+	}
+
+	if got := clk.Since(start); got != time.Second {
+		t.Errorf("expected the or-channel to close after 1s, got %v", got)
+	}
+
+	// the one second signal closed the tree, not any of the others
+	for i, s := range signals {
+		select {
+		case <-s:
+			if durations[i] != time.Second {
+				t.Errorf("signal %v closed unexpectedly", durations[i])
+			}
+		default:
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package or_done_channel
 
+import "concurrency-patterns/panic_recovery"
+
 // OrDone wraps the read from a channel c with a select statement that also selects from a done channel
 //
 // this approach allows to work with channels from disparate parts of a system
@@ -15,6 +17,7 @@ func OrDone(
 
 	go func() {
 		defer close(valStream)
+		defer panic_recovery.Recover("OrDone", panic_recovery.SendTo(done, valStream))
 
 		for {
 			select {
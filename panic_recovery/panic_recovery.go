@@ -0,0 +1,137 @@
+package panic_recovery
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// a panic in a goroutine can't be recovered by the goroutine that started it, so a single bad value
+// - e.g. a failed type assertion in pipeline.ToInt - would crash the whole process.
+//
+// every goroutine of this module therefore defers Recover. It converts the panic into a *PanicError and reports it
+// to the supervisor. The rule for the consumer of a stage is:
+//
+// 1. if the element type of the stage's channel can carry an error - it is error, or it implements ErrorCarrier like
+//    pipeline.Result - the panic is delivered as the last element, and then the channel is closed
+// 2. otherwise, the channel is only closed. This includes chan any: its consumer expects values, not errors, and a
+//    *PanicError in the middle of the values would only make the next stage panic (e.g. ToInt's type assertion)
+//
+// stages apply the rule by passing SendTo to Recover; goroutines without an output channel pass nil.
+
+// PanicError is the error a recovered panic is converted into
+type PanicError struct {
+	Stage string // name of the stage whose goroutine panicked
+	Value any    // the value passed to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in stage %s: %v", e.Stage, e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error (e.g. a *runtime.TypeAssertionError)
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Supervisor is notified about every recovered panic
+type Supervisor func(err *PanicError)
+
+var (
+	supervisor   atomic.Pointer[Supervisor]
+	crashOnPanic atomic.Bool
+)
+
+// logSupervisor is the default Supervisor; it logs the panic and its stack trace
+func logSupervisor(err *PanicError) {
+	log.Printf("%v\n%s", err, err.Stack)
+}
+
+// SetSupervisor replaces the Supervisor that is notified about recovered panics; nil restores the default, which logs them
+func SetSupervisor(s Supervisor) {
+	if s == nil {
+		supervisor.Store(nil)
+		return
+	}
+	supervisor.Store(&s)
+}
+
+// SetCrashOnPanic disables recovery, so a panic crashes the process with its original stack trace
+//
+// this is meant for debugging, where a crash is more helpful than an error value.
+func SetCrashOnPanic(crash bool) {
+	crashOnPanic.Store(crash)
+}
+
+// Recover recovers a panic of the calling goroutine; it must be deferred directly:
+//
+//	defer close(valueStream)
+//	defer panic_recovery.Recover("Take", panic_recovery.SendTo(done, valueStream))
+//
+// the recovered panic is reported to the supervisor and, if deliver is not nil, passed to deliver.
+// Because deferred calls run in reverse order, Recover runs before the stage closes its channels.
+func Recover(stage string, deliver func(err *PanicError)) {
+	if crashOnPanic.Load() {
+		return
+	}
+
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	err := &PanicError{Stage: stage, Value: v, Stack: debug.Stack()}
+
+	report := logSupervisor
+	if s := supervisor.Load(); s != nil {
+		report = *s
+	}
+	report(err)
+
+	if deliver != nil {
+		deliver(err)
+	}
+}
+
+// ErrorCarrier is implemented by element types that can carry an error in place of a value, such as pipeline.Result
+type ErrorCarrier interface {
+	// CarryError returns a value of the same type that carries err
+	CarryError(err error) any
+}
+
+// SendTo returns a deliver function for Recover that sends the error on c, unless done is closed
+//
+// it returns nil if T can't carry an error, so the stage only closes c - see the rule at the top of this file.
+func SendTo[T any](done <-chan any, c chan<- T) func(err *PanicError) {
+	var carry func(err *PanicError) T
+
+	var zero T
+	switch z := any(zero).(type) {
+	case ErrorCarrier:
+		carry = func(err *PanicError) T { return z.CarryError(err).(T) }
+	default:
+		// the zero value of an interface type is nil, so error has to be recognized by its pointer type
+		if _, ok := any((*T)(nil)).(*error); !ok {
+			return nil
+		}
+		carry = func(err *PanicError) T { return any(err).(T) }
+	}
+
+	return func(err *PanicError) {
+		select {
+		case <-done:
+		case c <- carry(err):
+		}
+	}
+}
+
+// Go runs fn in a new goroutine that recovers panics and reports them to the supervisor
+func Go(stage string, fn func()) {
+	go func() {
+		defer Recover(stage, nil)
+		fn()
+	}()
+}
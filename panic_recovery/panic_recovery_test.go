@@ -0,0 +1,129 @@
+package panic_recovery_test
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"concurrency-patterns/pipeline"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestRecover_TypedStageReportsToSupervisor(t *testing.T) {
+	reported := make(chan *panic_recovery.PanicError, 1)
+	panic_recovery.SetSupervisor(func(err *panic_recovery.PanicError) { reported <- err })
+	defer panic_recovery.SetSupervisor(nil)
+
+	done := make(chan any)
+	defer close(done)
+
+	// ToInt can't carry the error, so its channel is closed and the supervisor is told
+	for range pipeline.ToInt(done, pipeline.Repeat(done, "not an int")) {
+		t.Fatalf("expected no values")
+	}
+
+	err := <-reported
+	if err.Stage != "ToInt" {
+		t.Errorf("expected stage ToInt, got %q", err.Stage)
+	}
+	var typeErr *runtime.TypeAssertionError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("expected the type assertion error to be unwrapped, got %v", err.Value)
+	}
+	if len(err.Stack) == 0 {
+		t.Errorf("expected a stack trace")
+	}
+}
+
+func TestRecover_AnyStageClosesAndReports(t *testing.T) {
+	reported := make(chan *panic_recovery.PanicError, 1)
+	panic_recovery.SetSupervisor(func(err *panic_recovery.PanicError) { reported <- err })
+	defer panic_recovery.SetSupervisor(nil)
+
+	done := make(chan any)
+	defer close(done)
+
+	calls := 0
+	fn := func() any {
+		if calls++; calls == 3 {
+			panic("boom")
+		}
+		return calls
+	}
+
+	// the panic isn't sent on the chan any, so ToInt only sees the values before it
+	var values []int
+	for v := range pipeline.ToInt(done, pipeline.RepeatFn(done, fn)) {
+		values = append(values, v)
+	}
+
+	if len(values) != 2 {
+		t.Fatalf("expected the 2 values before the panic, got %v", values)
+	}
+	if err := <-reported; err.Stage != "RepeatFn" || err.Value != "boom" {
+		t.Errorf("expected the panic of RepeatFn to be reported, got %v", err)
+	}
+	select {
+	case err := <-reported:
+		t.Errorf("expected ToInt not to panic, got %v", err)
+	default:
+	}
+}
+
+func TestRecover_ResultStageDeliversToConsumer(t *testing.T) {
+	panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+	defer panic_recovery.SetSupervisor(nil)
+
+	done := make(chan any)
+	defer close(done)
+
+	resultStream := make(chan pipeline.Result[int], 3)
+	for i := range 3 {
+		resultStream <- pipeline.Result[int]{Value: i}
+	}
+	close(resultStream)
+
+	keep := func(r pipeline.Result[int]) bool {
+		if r.Value == 1 {
+			panic("boom")
+		}
+		return true
+	}
+
+	var results []pipeline.Result[int]
+	for r := range pipeline.Filter(done, resultStream, keep) {
+		results = append(results, r)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected a value and an error, got %v", results)
+	}
+	if err, ok := results[1].Error.(*panic_recovery.PanicError); !ok || err.Stage != "Filter" || err.Value != "boom" {
+		t.Errorf("expected a PanicError from Filter, got %#v", results[1])
+	}
+}
+
+func TestSendTo(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	err := &panic_recovery.PanicError{Stage: "test", Value: "boom"}
+
+	if panic_recovery.SendTo(done, make(chan any)) != nil {
+		t.Errorf("expected no deliver function for chan any")
+	}
+	if panic_recovery.SendTo(done, make(chan int)) != nil {
+		t.Errorf("expected no deliver function for chan int")
+	}
+
+	errStream := make(chan error, 1)
+	panic_recovery.SendTo(done, errStream)(err)
+	if got := <-errStream; got != err {
+		t.Errorf("expected the PanicError on chan error, got %v", got)
+	}
+
+	resultStream := make(chan pipeline.Result[string], 1)
+	panic_recovery.SendTo(done, resultStream)(err)
+	if got := <-resultStream; got.Error != err {
+		t.Errorf("expected a Result with the PanicError, got %#v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"concurrency-patterns/panic_recovery"
 	"fmt"
 	"math/rand"
 )
@@ -28,6 +29,7 @@ func Generator(done <-chan any, integers ...int) <-chan int {
 
 	go func() {
 		defer close(intStream)
+		defer panic_recovery.Recover("Generator", panic_recovery.SendTo(done, intStream))
 
 		for _, i := range integers {
 			select {
@@ -49,6 +51,7 @@ func MultiplyChannel(
 	multipliedStream := make(chan int)
 	go func() {
 		defer close(multipliedStream)
+		defer panic_recovery.Recover("MultiplyChannel", panic_recovery.SendTo(done, multipliedStream))
 
 		for i := range intStream {
 			select {
@@ -70,6 +73,7 @@ func AddChannel(
 	addedStream := make(chan int)
 	go func() {
 		defer close(addedStream)
+		defer panic_recovery.Recover("AddChannel", panic_recovery.SendTo(done, addedStream))
 
 		for i := range intStream {
 			select {
@@ -108,6 +112,7 @@ func Repeat(
 
 	go func() {
 		defer close(valueStream)
+		defer panic_recovery.Recover("Repeat", panic_recovery.SendTo(done, valueStream))
 
 		for {
 			for _, v := range values {
@@ -132,6 +137,7 @@ func RepeatFn(
 
 	go func() {
 		defer close(valueStream)
+		defer panic_recovery.Recover("RepeatFn", panic_recovery.SendTo(done, valueStream))
 
 		for {
 			select {
@@ -154,6 +160,7 @@ func Take(
 
 	go func() {
 		defer close(takeStream)
+		defer panic_recovery.Recover("Take", panic_recovery.SendTo(done, takeStream))
 		for i := 0; i < num; i++ {
 			select {
 			case <-done:
@@ -212,6 +219,8 @@ func ChannelProcessingExec3() {
 }
 
 // ToString converts the values sent via valueStream to a string
+//
+// a value that is not a string panics the stage; use Cast or FormatString if the stream may contain other types
 func ToString(
 	done <-chan any,
 	valueStream <-chan any,
@@ -220,6 +229,7 @@ func ToString(
 
 	go func() {
 		defer close(stringStream)
+		defer panic_recovery.Recover("ToString", panic_recovery.SendTo(done, stringStream))
 
 		for v := range valueStream {
 			select {
@@ -234,6 +244,8 @@ func ToString(
 }
 
 // ToInt converts the values sent via valueStream to int
+//
+// a value that is not an int panics the stage; use Cast or ParseInt if the stream may contain other types
 func ToInt(
 	done <-chan any,
 	valueStream <-chan any,
@@ -242,6 +254,7 @@ func ToInt(
 
 	go func() {
 		defer close(stringStream)
+		defer panic_recovery.Recover("ToInt", panic_recovery.SendTo(done, stringStream))
 
 		for v := range valueStream {
 			select {
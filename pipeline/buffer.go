@@ -0,0 +1,300 @@
+package pipeline
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// Queuing
+//
+// queuing is the act of accepting work for your pipeline even though the pipeline is not yet ready for it.
+// It decouples a stage from the stage after it, so the runtime of one stage no longer impacts the runtime of the other.
+//
+// Katherine: queuing should be implemented either
+// 1. at the entrance to your pipeline, or
+// 2. in stages where batching will lead to higher efficiency.
+//
+// adding queuing prematurely can hide synchronization issues such as deadlocks and livelocks,
+// and queuing will almost never speed up the total runtime of a program; it only allows the program to behave differently.
+
+// OverflowPolicy decides what a Buffer stage does with a value when its queue is full
+type OverflowPolicy int
+
+const (
+	// Block stops reading from the upstream until the downstream made room again (like a buffered channel)
+	Block OverflowPolicy = iota
+	// DropNewest discards the value that did not fit
+	DropNewest
+	// DropOldest discards the value at the head of the queue to make room for the new one
+	DropOldest
+	// SpillToDisk moves values that don't fit into a Spiller and replays them in order
+	SpillToDisk
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "Block"
+	case DropNewest:
+		return "DropNewest"
+	case DropOldest:
+		return "DropOldest"
+	case SpillToDisk:
+		return "SpillToDisk"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// Spiller stores the values a Buffer stage with the SpillToDisk policy can't keep in memory
+//
+// Pop must return the values in the order they were pushed. A Spiller is used by a single goroutine only.
+type Spiller interface {
+	Push(v any) error
+	Pop() (v any, ok bool, err error)
+	Len() int
+	Close() error
+}
+
+// WithSpiller sets the Spiller used by the SpillToDisk policy
+//
+// without this option, values are spilled to a temporary file that is removed when the Buffer stage exits.
+// The Buffer stage takes ownership of spiller and closes it on exit. spill_queue.Queue is a Spiller that survives restarts.
+func WithSpiller(spiller Spiller) Option {
+	return func(o *options) { o.spiller = spiller }
+}
+
+// BufferStats reports the occupancy of a Buffer stage; it is safe to read while the stage is running
+type BufferStats struct {
+	capacity int
+	len      atomic.Int64
+	spilled  atomic.Int64
+	dropped  atomic.Int64
+}
+
+// Cap returns the number of values the Buffer keeps in memory
+func (s *BufferStats) Cap() int { return s.capacity }
+
+// Len returns the number of values currently queued in memory
+func (s *BufferStats) Len() int { return int(s.len.Load()) }
+
+// Spilled returns the number of values currently spilled
+func (s *BufferStats) Spilled() int { return int(s.spilled.Load()) }
+
+// Dropped returns the total number of values discarded by the DropNewest and DropOldest policies
+func (s *BufferStats) Dropped() int { return int(s.dropped.Load()) }
+
+// Buffer decouples the upstream from the downstream with a queue of up to size values
+//
+// when the queue is full, policy decides what happens to the next value. Buffer stops at the done channel, or once
+// valueStream is closed and every queued value has been delivered. If spilling fails, the error is sent downstream
+// and the stage stops.
+func Buffer(
+	done <-chan any,
+	valueStream <-chan any,
+	size int,
+	policy OverflowPolicy,
+	opts ...Option,
+) (<-chan any, *BufferStats) {
+	o := newOptions(opts...)
+	stats := &BufferStats{capacity: max(size, 1)}
+	bufferedStream := make(chan any)
+
+	go func() {
+		defer close(bufferedStream)
+		defer panic_recovery.Recover("Buffer", panic_recovery.SendTo(done, bufferedStream))
+
+		spiller := o.spiller
+		if policy == SpillToDisk && spiller == nil {
+			var err error
+			if spiller, err = newFileSpiller(); err != nil {
+				select {
+				case <-done:
+				case bufferedStream <- err:
+				}
+				return
+			}
+		}
+		if spiller != nil {
+			defer spiller.Close()
+		}
+
+		queue := make([]any, 0, stats.capacity)
+		spilled := func() int {
+			if spiller == nil {
+				return 0
+			}
+			return spiller.Len()
+		}
+
+		enqueue := func(v any) error {
+			defer func() {
+				stats.len.Store(int64(len(queue)))
+				stats.spilled.Store(int64(spilled()))
+			}()
+
+			// as long as values are spilled, new values have to be spilled as well to keep the order
+			if len(queue) < stats.capacity && spilled() == 0 {
+				queue = append(queue, v)
+				return nil
+			}
+
+			switch policy {
+			case DropNewest:
+				stats.dropped.Add(1)
+			case DropOldest:
+				queue = append(queue[1:], v)
+				stats.dropped.Add(1)
+			case SpillToDisk:
+				return spiller.Push(v)
+			}
+			return nil
+		}
+
+		dequeue := func() error {
+			defer func() {
+				stats.len.Store(int64(len(queue)))
+				stats.spilled.Store(int64(spilled()))
+			}()
+
+			queue = queue[1:]
+			if spilled() == 0 {
+				return nil
+			}
+
+			v, ok, err := spiller.Pop()
+			if ok {
+				queue = append(queue, v)
+			}
+			return err
+		}
+
+		for {
+			// a nil channel blocks forever, which disables the corresponding case
+			var outStream chan<- any
+			var next any
+			if len(queue) > 0 {
+				outStream = bufferedStream
+				next = queue[0]
+			}
+
+			inStream := valueStream
+			if policy == Block && len(queue) >= stats.capacity {
+				inStream = nil
+			}
+
+			if valueStream == nil && outStream == nil {
+				return
+			}
+
+			var err error
+			select {
+			case <-done:
+				return
+			case v, ok := <-inStream:
+				if !ok {
+					valueStream = nil
+					continue
+				}
+				err = enqueue(v)
+			case outStream <- next:
+				err = dequeue()
+			}
+
+			if err != nil {
+				select {
+				case <-done:
+				case bufferedStream <- err:
+				}
+				return
+			}
+		}
+	}()
+
+	return bufferedStream, stats
+}
+
+// fileSpiller spills gob encoded values to a temporary file
+//
+// values are appended by an encoder and read back in order by a decoder on a second file handle.
+// Once every spilled value has been read, the file is truncated, so it only grows while the downstream is behind.
+//
+// gob needs to know the concrete types stored in an interface value: basic types work out of the box,
+// custom types must be registered with gob.Register.
+type fileSpiller struct {
+	w, r *os.File
+	enc  *gob.Encoder
+	dec  *gob.Decoder
+	len  int
+}
+
+func newFileSpiller() (*fileSpiller, error) {
+	w, err := os.CreateTemp("", "pipeline-spill-*")
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := os.Open(w.Name())
+	if err != nil {
+		w.Close()
+		os.Remove(w.Name())
+		return nil, err
+	}
+
+	return &fileSpiller{w: w, r: r, enc: gob.NewEncoder(w), dec: gob.NewDecoder(r)}, nil
+}
+
+func (s *fileSpiller) Push(v any) error {
+	if err := s.enc.Encode(&v); err != nil {
+		return fmt.Errorf("pipeline: spill %T: %w", v, err)
+	}
+	s.len++
+	return nil
+}
+
+func (s *fileSpiller) Pop() (any, bool, error) {
+	if s.len == 0 {
+		return nil, false, nil
+	}
+
+	var v any
+	if err := s.dec.Decode(&v); err != nil {
+		return nil, false, fmt.Errorf("pipeline: read spilled value: %w", err)
+	}
+	s.len--
+
+	if s.len == 0 {
+		return v, true, s.reset()
+	}
+	return v, true, nil
+}
+
+// reset truncates the spill file and starts new gob streams, because a gob stream can't be rewound
+func (s *fileSpiller) reset() error {
+	if err := s.w.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := s.r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	s.enc = gob.NewEncoder(s.w)
+	s.dec = gob.NewDecoder(s.r)
+	return nil
+}
+
+func (s *fileSpiller) Len() int {
+	return s.len
+}
+
+func (s *fileSpiller) Close() error {
+	s.r.Close()
+	s.w.Close()
+	return os.Remove(s.w.Name())
+}
@@ -0,0 +1,112 @@
+package pipeline_test
+
+import (
+	"concurrency-patterns/pipeline"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBuffer_Policies(t *testing.T) {
+	tests := []struct {
+		policy  pipeline.OverflowPolicy
+		want    []any
+		dropped int
+	}{
+		{pipeline.Block, []any{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 0},
+		{pipeline.SpillToDisk, []any{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 0},
+		{pipeline.DropNewest, []any{0, 1, 2}, 7},
+		{pipeline.DropOldest, []any{7, 8, 9}, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			valueStream := make(chan any)
+			buffered, stats := pipeline.Buffer(done, valueStream, 3, tt.policy)
+
+			// fill the buffer before anybody reads, so the overflow policy kicks in
+			go func() {
+				defer close(valueStream)
+				for i := 0; i < 10; i++ {
+					valueStream <- i
+				}
+			}()
+			if tt.policy != pipeline.Block {
+				for stats.Len()+stats.Spilled()+stats.Dropped() < 10 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			var got []any
+			for v := range buffered {
+				got = append(got, v)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if stats.Dropped() != tt.dropped {
+				t.Errorf("got %d dropped values, want %d", stats.Dropped(), tt.dropped)
+			}
+		})
+	}
+}
+
+// BenchmarkBuffer measures the overhead of queuing compared to BenchmarkGeneric
+func BenchmarkBuffer(b *testing.B) {
+	for _, size := range []int{1, 16, 256} {
+		for _, policy := range []pipeline.OverflowPolicy{pipeline.Block, pipeline.DropOldest, pipeline.SpillToDisk} {
+			b.Run(fmt.Sprintf("%v/size=%d", policy, size), func(b *testing.B) {
+				done := make(chan any)
+				defer close(done)
+
+				b.ResetTimer()
+				buffered, _ := pipeline.Buffer(done, pipeline.Take(done, pipeline.Repeat(done, "a"), b.N), size, policy)
+				for range pipeline.ToString(done, buffered) {
+					// intentionally left blank
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkBufferSlowStage shows the effect of a queue in front of a stage with a bursty runtime
+//
+// producing a value takes 10µs, and every 100th value takes 1ms to consume. With a queue, the producer keeps producing
+// while the consumer is busy, but - as the book points out - the total runtime stays about the same:
+// the pipeline is only as fast as its slowest stage.
+func BenchmarkBufferSlowStage(b *testing.B) {
+	// spin is used instead of time.Sleep, whose resolution is too coarse for this benchmark
+	spin := func(d time.Duration) {
+		for start := time.Now(); time.Since(start) < d; {
+		}
+	}
+
+	for _, size := range []int{0, 100} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			done := make(chan any)
+			defer close(done)
+
+			produce := func() any {
+				spin(10 * time.Microsecond)
+				return "a"
+			}
+
+			valueStream := pipeline.Take(done, pipeline.RepeatFn(done, produce), b.N)
+			if size > 0 {
+				valueStream, _ = pipeline.Buffer(done, valueStream, size, pipeline.Block)
+			}
+
+			b.ResetTimer()
+			i := 0
+			for range valueStream {
+				if i++; i%100 == 0 {
+					spin(time.Millisecond)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Bridge, FanIn and Repeat produce <-chan any streams. ToString and ToInt narrow them with unchecked type assertions,
+// so a single unexpected value panics the stage. The stages in this file narrow a stream safely: a value that can't be
+// converted is sent downstream as a *ConversionError, or to a dead-letter channel set with WithDeadLetter.
+
+// ConversionError reports a value that a stage could not convert
+type ConversionError struct {
+	Stage  string
+	Value  any
+	Target string
+	Err    error // the error of the parser, if any
+}
+
+func (e *ConversionError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("pipeline: %s: cannot convert %v (%T) to %s: %v", e.Stage, e.Value, e.Value, e.Target, e.Err)
+	}
+	return fmt.Sprintf("pipeline: %s: cannot convert %v (%T) to %s", e.Stage, e.Value, e.Value, e.Target)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+// WithDeadLetter sends the *ConversionError of every value that can't be converted to deadLetter,
+// instead of sending it downstream; the caller owns deadLetter, and must keep reading from it
+func WithDeadLetter(deadLetter chan<- error) Option {
+	return func(o *options) { o.deadLetter = deadLetter }
+}
+
+// Cast narrows the values of valueStream to T with a checked type assertion
+func Cast[T any](
+	done <-chan any,
+	valueStream <-chan any,
+	opts ...Option,
+) <-chan Result[T] {
+	return convert(done, "Cast", valueStream, func(v any) (T, error) {
+		t, ok := v.(T)
+		if !ok {
+			return t, errNotConvertible
+		}
+		return t, nil
+	}, newOptions(opts...))
+}
+
+// ParseInt converts the values of valueStream to int; strings are parsed, ints are passed on
+func ParseInt(
+	done <-chan any,
+	valueStream <-chan any,
+	opts ...Option,
+) <-chan Result[int] {
+	return convert(done, "ParseInt", valueStream, func(v any) (int, error) {
+		switch v := v.(type) {
+		case int:
+			return v, nil
+		case string:
+			return strconv.Atoi(v)
+		default:
+			return 0, errNotConvertible
+		}
+	}, newOptions(opts...))
+}
+
+// ParseFloat converts the values of valueStream to float64; strings are parsed, floats and ints are converted
+func ParseFloat(
+	done <-chan any,
+	valueStream <-chan any,
+	opts ...Option,
+) <-chan Result[float64] {
+	return convert(done, "ParseFloat", valueStream, func(v any) (float64, error) {
+		switch v := v.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		case int:
+			return float64(v), nil
+		case string:
+			return strconv.ParseFloat(v, 64)
+		default:
+			return 0, errNotConvertible
+		}
+	}, newOptions(opts...))
+}
+
+// FormatString converts the values of valueStream to string; strings are passed on, and a fmt.Stringer is formatted
+func FormatString(
+	done <-chan any,
+	valueStream <-chan any,
+	opts ...Option,
+) <-chan Result[string] {
+	return convert(done, "FormatString", valueStream, func(v any) (string, error) {
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case fmt.Stringer:
+			return v.String(), nil
+		default:
+			return "", errNotConvertible
+		}
+	}, newOptions(opts...))
+}
+
+// errNotConvertible marks a value of a type the stage doesn't convert; it's replaced by a ConversionError without Err
+var errNotConvertible = errors.New("not convertible")
+
+// convert implements the stages in this file
+func convert[T any](
+	done <-chan any,
+	stage string,
+	valueStream <-chan any,
+	fn func(v any) (T, error),
+	o options,
+) <-chan Result[T] {
+	target := reflect.TypeFor[T]().String()
+
+	return operate(done, stage, valueStream, func(v any, send func(Result[T]) bool) bool {
+		t, err := fn(v)
+		if err == nil {
+			return send(Result[T]{Value: t})
+		}
+
+		convErr := &ConversionError{Stage: stage, Value: v, Target: target}
+		if err != errNotConvertible {
+			convErr.Err = err
+		}
+
+		if o.deadLetter != nil {
+			return sendValue(done, o.deadLetter, error(convErr))
+		}
+		return send(Result[T]{Error: convErr})
+	}, nil)
+}
+
+// ChannelProcessingExec10 sums the numbers of a stream that also contains values that aren't numbers
+//
+// the values that can't be parsed are sent to a dead-letter channel, and printed once the sum is known.
+func ChannelProcessingExec10() {
+	done := make(chan any)
+	defer close(done)
+
+	deadLetter := make(chan error, 10)
+
+	var sum int
+	for result := range ParseInt(done, Take(done, Repeat(done, 1, "2", "three", 4.0), 8), WithDeadLetter(deadLetter)) {
+		sum += result.Value
+	}
+	close(deadLetter)
+
+	fmt.Println("sum:", sum)
+	for err := range deadLetter {
+		fmt.Println(err)
+	}
+}
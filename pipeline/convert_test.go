@@ -0,0 +1,130 @@
+package pipeline_test
+
+import (
+	"concurrency-patterns/pipeline"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		convert func(done <-chan any, valueStream <-chan any) <-chan pipeline.Result[string]
+		in      []any
+		want    string
+		errs    int
+	}{
+		{
+			name: "Cast",
+			convert: func(done <-chan any, valueStream <-chan any) <-chan pipeline.Result[string] {
+				return pipeline.Cast[string](done, valueStream)
+			},
+			in:   []any{"a", 1, "b", nil},
+			want: "[a b]",
+			errs: 2,
+		},
+		{
+			name: "ParseInt",
+			convert: func(done <-chan any, valueStream <-chan any) <-chan pipeline.Result[string] {
+				return format(done, pipeline.ParseInt(done, valueStream))
+			},
+			in:   []any{1, "2", "three", 4.0},
+			want: "[1 2]",
+			errs: 2,
+		},
+		{
+			name: "ParseFloat",
+			convert: func(done <-chan any, valueStream <-chan any) <-chan pipeline.Result[string] {
+				return format(done, pipeline.ParseFloat(done, valueStream))
+			},
+			in:   []any{1, "2.5", float32(0.5), "x", true},
+			want: "[1 2.5 0.5]",
+			errs: 2,
+		},
+		{
+			name: "FormatString",
+			convert: func(done <-chan any, valueStream <-chan any) <-chan pipeline.Result[string] {
+				return pipeline.FormatString(done, valueStream)
+			},
+			in:   []any{"a", time.Second, 3},
+			want: "[a 1s]",
+			errs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			var got []string
+			var errs int
+			for result := range tt.convert(done, pipeline.Take(done, pipeline.Repeat(done, tt.in...), len(tt.in))) {
+				var convErr *pipeline.ConversionError
+				switch {
+				case errors.As(result.Error, &convErr):
+					errs++
+				case result.Error != nil:
+					t.Fatalf("got %v, want a *pipeline.ConversionError", result.Error)
+				default:
+					got = append(got, result.Value)
+				}
+			}
+
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if errs != tt.errs {
+				t.Errorf("got %d conversion errors, want %d", errs, tt.errs)
+			}
+		})
+	}
+}
+
+func TestConvert_DeadLetter(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	deadLetter := make(chan error, 2)
+	ints := pipeline.ParseInt(done, pipeline.Take(done, pipeline.Repeat(done, "1", "x", 2, nil), 4), pipeline.WithDeadLetter(deadLetter))
+
+	var got []int
+	for result := range ints {
+		if result.Error != nil {
+			t.Fatalf("got %v downstream, want it in the dead-letter channel", result.Error)
+		}
+		got = append(got, result.Value)
+	}
+	close(deadLetter)
+
+	if fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("got %v, want [1 2]", got)
+	}
+
+	var numErr *strconv.NumError
+	if err := <-deadLetter; !errors.As(err, &numErr) {
+		t.Errorf("got %v, want the error of the parser", err)
+	}
+	if err := <-deadLetter; err == nil || errors.Unwrap(err) != nil {
+		t.Errorf("got %v, want a conversion error without a parser error", err)
+	}
+}
+
+// format converts a stream of Results to a stream of formatted Results, so the tests of all stages have the same type
+func format[T any](done <-chan any, results <-chan pipeline.Result[T]) <-chan pipeline.Result[string] {
+	formatted := make(chan pipeline.Result[string])
+	go func() {
+		defer close(formatted)
+		for result := range results {
+			select {
+			case <-done:
+				return
+			case formatted <- pipeline.Result[string]{Value: fmt.Sprint(result.Value), Error: result.Error}:
+			}
+		}
+	}()
+	return formatted
+}
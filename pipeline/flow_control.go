@@ -0,0 +1,235 @@
+package pipeline
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"fmt"
+	"time"
+)
+
+// bursty streams - user input, alerts, metrics - often carry more values than the consumer needs.
+// The stages in this file thin them out based on time, as measured by the clock set with WithClock:
+//
+// 1 Debounce waits for a burst to end, and sends its last value
+// 2 ThrottleFirst sends the first value of a burst, and drops the rest of the interval
+// 3 ThrottleLast sends the last value of every interval that started with a value
+// 4 Sample sends the latest value on every tick of a fixed-rate ticker
+//
+// a stage holding a pending value when its upstream is closed sends it before closing its channel,
+// unless WithFlushOnClose(false) is set.
+
+// WithFlushOnClose sets whether a stage sends its pending value when its upstream is closed; the default is true
+func WithFlushOnClose(flush bool) Option {
+	return func(o *options) { o.discardOnClose = !flush }
+}
+
+// Debounce sends a value once no other value followed it for quiet
+func Debounce[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	quiet time.Duration,
+	opts ...Option,
+) <-chan T {
+	o := newOptions(opts...)
+	debouncedStream := make(chan T)
+
+	go func() {
+		defer close(debouncedStream)
+		defer panic_recovery.Recover("Debounce", panic_recovery.SendTo(done, debouncedStream))
+
+		timer := o.clock.NewTimer(quiet)
+		timer.Stop()
+		defer timer.Stop()
+
+		// a nil channel blocks forever, so the timer only fires while a value is pending
+		var pending T
+		var expired <-chan time.Time
+
+		for {
+			select {
+			case <-done:
+				return
+			case v, ok := <-valueStream:
+				if !ok {
+					if expired != nil && !o.discardOnClose {
+						sendValue(done, debouncedStream, pending)
+					}
+					return
+				}
+
+				pending = v
+				timer.Reset(quiet)
+				expired = timer.C()
+			case <-expired:
+				expired = nil
+				if !sendValue(done, debouncedStream, pending) {
+					return
+				}
+			}
+		}
+	}()
+
+	return debouncedStream
+}
+
+// ThrottleFirst sends a value, and then drops every value received within interval after it
+func ThrottleFirst[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	interval time.Duration,
+	opts ...Option,
+) <-chan T {
+	o := newOptions(opts...)
+	throttledStream := make(chan T)
+
+	go func() {
+		defer close(throttledStream)
+		defer panic_recovery.Recover("ThrottleFirst", panic_recovery.SendTo(done, throttledStream))
+
+		var last time.Time
+		sent := false
+
+		for {
+			select {
+			case <-done:
+				return
+			case v, ok := <-valueStream:
+				if !ok {
+					return
+				}
+
+				if sent && o.clock.Since(last) < interval {
+					continue
+				}
+
+				sent, last = true, o.clock.Now()
+				if !sendValue(done, throttledStream, v) {
+					return
+				}
+			}
+		}
+	}()
+
+	return throttledStream
+}
+
+// ThrottleLast sends the last value received within interval after the first one, and then waits for the next value
+func ThrottleLast[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	interval time.Duration,
+	opts ...Option,
+) <-chan T {
+	o := newOptions(opts...)
+	throttledStream := make(chan T)
+
+	go func() {
+		defer close(throttledStream)
+		defer panic_recovery.Recover("ThrottleLast", panic_recovery.SendTo(done, throttledStream))
+
+		timer := o.clock.NewTimer(interval)
+		timer.Stop()
+		defer timer.Stop()
+
+		// the interval starts with the first value after the previous one was sent
+		var pending T
+		var expired <-chan time.Time
+
+		for {
+			select {
+			case <-done:
+				return
+			case v, ok := <-valueStream:
+				if !ok {
+					if expired != nil && !o.discardOnClose {
+						sendValue(done, throttledStream, pending)
+					}
+					return
+				}
+
+				pending = v
+				if expired == nil {
+					timer.Reset(interval)
+					expired = timer.C()
+				}
+			case <-expired:
+				expired = nil
+				if !sendValue(done, throttledStream, pending) {
+					return
+				}
+			}
+		}
+	}()
+
+	return throttledStream
+}
+
+// Sample sends the latest value on every tick, if a value was received since the previous tick
+func Sample[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	interval time.Duration,
+	opts ...Option,
+) <-chan T {
+	o := newOptions(opts...)
+	sampledStream := make(chan T)
+
+	go func() {
+		defer close(sampledStream)
+		defer panic_recovery.Recover("Sample", panic_recovery.SendTo(done, sampledStream))
+
+		ticker := o.clock.NewTicker(interval)
+		defer ticker.Stop()
+
+		var latest T
+		fresh := false
+
+		for {
+			select {
+			case <-done:
+				return
+			case v, ok := <-valueStream:
+				if !ok {
+					if fresh && !o.discardOnClose {
+						sendValue(done, sampledStream, latest)
+					}
+					return
+				}
+
+				latest, fresh = v, true
+			case <-ticker.C():
+				if !fresh {
+					continue
+				}
+
+				fresh = false
+				if !sendValue(done, sampledStream, latest) {
+					return
+				}
+			}
+		}
+	}()
+
+	return sampledStream
+}
+
+// sendValue sends v on c unless done is closed first, and reports whether it was sent
+func sendValue[T any](done <-chan any, c chan<- T, v T) bool {
+	select {
+	case <-done:
+		return false
+	case c <- v:
+		return true
+	}
+}
+
+// ChannelProcessingExec7 debounces a burst of timestamps: only the last timestamp of the burst is printed
+func ChannelProcessingExec7() {
+	done := make(chan any)
+	defer close(done)
+
+	burst := Take(done, RepeatFn(done, func() any { return time.Now().Nanosecond() }), 10)
+
+	for v := range Debounce(done, burst, 100*time.Millisecond) {
+		fmt.Println(v)
+	}
+}
@@ -0,0 +1,170 @@
+package pipeline_test
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/pipeline"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	tests := []struct {
+		flush bool
+		want  []int
+	}{
+		{true, []int{1, 2}},
+		{false, []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("flush=%v", tt.flush), func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			clk := clock.NewFake(time.Now())
+			valueStream := make(chan int)
+			debounced := pipeline.Debounce(done, valueStream, time.Second, pipeline.WithClock(clk), pipeline.WithFlushOnClose(tt.flush))
+
+			valueStream <- 1
+			clk.BlockUntil(1)
+			clk.Advance(time.Second)
+			got := []int{<-debounced}
+
+			// 2 is still pending when the upstream is closed
+			valueStream <- 2
+			close(valueStream)
+			for v := range debounced {
+				got = append(got, v)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottleFirst(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	clk := clock.NewFake(time.Now())
+	valueStream := make(chan int)
+	throttled := pipeline.ThrottleFirst(done, valueStream, time.Second, pipeline.WithClock(clk))
+
+	collected := make(chan []int)
+	go func() { collected <- pipeline.CollectSlice(done, throttled) }()
+
+	// a completed send only guarantees that the previous value was checked against the clock, not the one just sent.
+	// So the value sent last before the interval ends is sent again after it: exactly one of the two passes.
+	valueStream <- 1
+	valueStream <- 2
+	clk.Advance(500 * time.Millisecond)
+	valueStream <- 2
+	valueStream <- 3
+	clk.Advance(500 * time.Millisecond)
+	valueStream <- 3
+	valueStream <- 4
+	close(valueStream)
+
+	if got, want := <-collected, []int{1, 3}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestThrottleLast(t *testing.T) {
+	tests := []struct {
+		flush bool
+		want  []int
+	}{
+		{true, []int{3, 5}},
+		{false, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("flush=%v", tt.flush), func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			clk := clock.NewFake(time.Now())
+			valueStream := make(chan int)
+			throttled := pipeline.ThrottleLast(done, valueStream, time.Second, pipeline.WithClock(clk), pipeline.WithFlushOnClose(tt.flush))
+
+			// the interval starts with 1, and ends with 3 as the last value received
+			valueStream <- 1
+			valueStream <- 2
+			valueStream <- 3
+			clk.BlockUntil(1)
+			clk.Advance(time.Second)
+			got := []int{<-throttled}
+
+			// without values, no interval is started
+			clk.Advance(5 * time.Second)
+
+			// 5 is still pending when the upstream is closed, half-way into the interval started by 4
+			valueStream <- 4
+			clk.BlockUntil(1)
+			clk.Advance(500 * time.Millisecond)
+			valueStream <- 5
+			close(valueStream)
+			for v := range throttled {
+				got = append(got, v)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSample(t *testing.T) {
+	tests := []struct {
+		name    string
+		flush   bool
+		pending bool // whether a value is pending when the upstream is closed
+		want    []int
+	}{
+		{"pending, flush", true, true, []int{2, 3, 4}},
+		{"pending, no flush", false, true, []int{2, 3}},
+		{"nothing pending", true, false, []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			clk := clock.NewFake(time.Now())
+			valueStream := make(chan int)
+			sampled := pipeline.Sample(done, valueStream, time.Second, pipeline.WithClock(clk), pipeline.WithFlushOnClose(tt.flush))
+			clk.BlockUntil(1)
+
+			// only the latest value of every tick is sent
+			valueStream <- 1
+			valueStream <- 2
+			clk.Advance(time.Second)
+			got := []int{<-sampled}
+
+			valueStream <- 3
+			clk.Advance(time.Second)
+			got = append(got, <-sampled)
+
+			if tt.pending {
+				valueStream <- 4
+			} else {
+				// a tick without a new value doesn't send the previous one again
+				clk.Advance(time.Second)
+			}
+			close(valueStream)
+			for v := range sampled {
+				got = append(got, v)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package pipeline
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"fmt"
+	"time"
+)
+
+// JoinKind selects which values a Join stage sends downstream
+type JoinKind int
+
+const (
+	// InnerJoin only sends the pairs of values with the same key
+	InnerJoin JoinKind = iota
+	// LeftJoin also sends every left value that was not matched by the time it left the window
+	LeftJoin
+)
+
+func (k JoinKind) String() string {
+	switch k {
+	case InnerJoin:
+		return "InnerJoin"
+	case LeftJoin:
+		return "LeftJoin"
+	default:
+		return fmt.Sprintf("JoinKind(%d)", int(k))
+	}
+}
+
+// JoinConfig configures a Join stage
+//
+// a stream never ends from the point of view of a join, so every value is only kept for matching while it is inside
+// a window: the last WindowSize values of its side, and no longer than WindowDuration. A zero bound is not enforced;
+// with both bounds zero, values are kept until both inputs are closed.
+type JoinConfig[K comparable, L, R any] struct {
+	Kind           JoinKind
+	LeftKey        func(L) K
+	RightKey       func(R) K
+	WindowSize     int
+	WindowDuration time.Duration
+	EmitUnmatched  bool // send the values that left the window without a match to a separate stream
+}
+
+// Joined is a value sent by a Join stage; Matched is only false for the unmatched left values of a LeftJoin
+type Joined[L, R any] struct {
+	Left    L
+	Right   R
+	Matched bool
+}
+
+// Unmatched is a value that left the window of a Join stage without a match
+type Unmatched[L, R any] struct {
+	Left     L
+	Right    R
+	FromLeft bool
+}
+
+// Join correlates the values of left and right that have the same key
+//
+// it's a symmetric hash join: a value arriving on either side is matched against the values of the other side
+// that are still in the window, and then kept in the window of its own side for later arrivals. Duplicate keys
+// are allowed; every pair of values with the same key is sent once.
+//
+// the second channel receives the values evicted without a match if cfg.EmitUnmatched is set, and is nil otherwise.
+// For a LeftJoin, unmatched left values are sent to the first channel, so only unmatched right values go to the second one.
+// Like with Tee, both channels must be read, or the stage blocks. Time is measured by the clock set with WithClock.
+func Join[K comparable, L, R any](
+	done <-chan any,
+	left <-chan L,
+	right <-chan R,
+	cfg JoinConfig[K, L, R],
+	opts ...Option,
+) (<-chan Joined[L, R], <-chan Unmatched[L, R]) {
+	o := newOptions(opts...)
+
+	joinedStream := make(chan Joined[L, R])
+	var unmatchedStream chan Unmatched[L, R]
+	if cfg.EmitUnmatched {
+		unmatchedStream = make(chan Unmatched[L, R])
+	}
+
+	go func() {
+		defer func() {
+			close(joinedStream)
+			if unmatchedStream != nil {
+				close(unmatchedStream)
+			}
+		}()
+		defer panic_recovery.Recover("Join", nil)
+
+		lefts := newJoinWindow(cfg.LeftKey)
+		rights := newJoinWindow(cfg.RightKey)
+
+		sendJoined := func(j Joined[L, R]) bool {
+			select {
+			case <-done:
+				return false
+			case joinedStream <- j:
+				return true
+			}
+		}
+
+		sendUnmatched := func(u Unmatched[L, R]) bool {
+			if unmatchedStream == nil {
+				return true
+			}
+			select {
+			case <-done:
+				return false
+			case unmatchedStream <- u:
+				return true
+			}
+		}
+
+		evictLeft := func() bool {
+			e := lefts.evict()
+			switch {
+			case e.matched:
+				return true
+			case cfg.Kind == LeftJoin:
+				return sendJoined(Joined[L, R]{Left: e.value})
+			default:
+				return sendUnmatched(Unmatched[L, R]{Left: e.value, FromLeft: true})
+			}
+		}
+
+		evictRight := func() bool {
+			e := rights.evict()
+			if e.matched {
+				return true
+			}
+			return sendUnmatched(Unmatched[L, R]{Right: e.value})
+		}
+
+		// evictOlderThan evicts the values of both sides that arrived before t, oldest first
+		evictOlderThan := func(t time.Time) bool {
+			for {
+				l, r := lefts.oldest(), rights.oldest()
+				switch {
+				case l != nil && l.at.Before(t) && (r == nil || !r.at.Before(l.at)):
+					if !evictLeft() {
+						return false
+					}
+				case r != nil && r.at.Before(t):
+					if !evictRight() {
+						return false
+					}
+				default:
+					return true
+				}
+			}
+		}
+
+		// the timer is armed for the oldest value in the window; a nil channel blocks forever while there is none
+		timer := o.clock.NewTimer(time.Hour)
+		timer.Stop()
+		defer timer.Stop()
+
+		var expired <-chan time.Time
+		arm := func() {
+			timer.Stop()
+			expired = nil
+
+			l, r := lefts.oldest(), rights.oldest()
+			if cfg.WindowDuration <= 0 || (l == nil && r == nil) {
+				return
+			}
+
+			var oldest time.Time
+			switch {
+			case l == nil:
+				oldest = r.at
+			case r == nil || l.at.Before(r.at):
+				oldest = l.at
+			default:
+				oldest = r.at
+			}
+
+			timer.Reset(cfg.WindowDuration - o.clock.Since(oldest))
+			expired = timer.C()
+		}
+
+		leftStream, rightStream := left, right
+		for leftStream != nil || rightStream != nil {
+			select {
+			case <-done:
+				return
+			case <-expired:
+				if !evictOlderThan(o.clock.Now().Add(-cfg.WindowDuration).Add(time.Nanosecond)) {
+					return
+				}
+			case l, ok := <-leftStream:
+				if !ok {
+					leftStream = nil
+					continue
+				}
+
+				e := lefts.add(l, o.clock.Now())
+				for _, r := range rights.match(e.key) {
+					e.matched, r.matched = true, true
+					if !sendJoined(Joined[L, R]{Left: l, Right: r.value, Matched: true}) {
+						return
+					}
+				}
+
+				if cfg.WindowSize > 0 && lefts.len() > cfg.WindowSize && !evictLeft() {
+					return
+				}
+			case r, ok := <-rightStream:
+				if !ok {
+					rightStream = nil
+					continue
+				}
+
+				e := rights.add(r, o.clock.Now())
+				for _, l := range lefts.match(e.key) {
+					e.matched, l.matched = true, true
+					if !sendJoined(Joined[L, R]{Left: l.value, Right: r, Matched: true}) {
+						return
+					}
+				}
+
+				if cfg.WindowSize > 0 && rights.len() > cfg.WindowSize && !evictRight() {
+					return
+				}
+			}
+
+			arm()
+		}
+
+		// both inputs are closed, so nothing can match anymore: flush the windows, every value arrived before now
+		evictOlderThan(o.clock.Now().Add(time.Nanosecond))
+	}()
+
+	return joinedStream, unmatchedStream
+}
+
+type joinEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	at      time.Time
+	matched bool
+}
+
+// joinWindow holds the values of one side of a join, in the order of their arrival and indexed by key
+type joinWindow[K comparable, V any] struct {
+	key   func(V) K
+	queue []*joinEntry[K, V]
+	byKey map[K][]*joinEntry[K, V]
+}
+
+func newJoinWindow[K comparable, V any](key func(V) K) *joinWindow[K, V] {
+	return &joinWindow[K, V]{key: key, byKey: make(map[K][]*joinEntry[K, V])}
+}
+
+func (w *joinWindow[K, V]) len() int {
+	return len(w.queue)
+}
+
+func (w *joinWindow[K, V]) add(v V, at time.Time) *joinEntry[K, V] {
+	e := &joinEntry[K, V]{key: w.key(v), value: v, at: at}
+	w.queue = append(w.queue, e)
+	w.byKey[e.key] = append(w.byKey[e.key], e)
+	return e
+}
+
+func (w *joinWindow[K, V]) match(key K) []*joinEntry[K, V] {
+	return w.byKey[key]
+}
+
+func (w *joinWindow[K, V]) oldest() *joinEntry[K, V] {
+	if len(w.queue) == 0 {
+		return nil
+	}
+	return w.queue[0]
+}
+
+// evict removes the oldest value from the window
+func (w *joinWindow[K, V]) evict() *joinEntry[K, V] {
+	e := w.queue[0]
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+
+	entries := w.byKey[e.key]
+	for i, other := range entries {
+		if other == e {
+			entries = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(entries) == 0 {
+		delete(w.byKey, e.key)
+	} else {
+		w.byKey[e.key] = entries
+	}
+
+	return e
+}
+
+// JoinExec correlates a stream of requests with a stream of responses by their ID
+//
+// the request without a response is reported once it leaves the window, and so is the response without a request.
+func JoinExec() {
+	done := make(chan any)
+	defer close(done)
+
+	type request struct {
+		ID   int
+		Path string
+	}
+	type response struct {
+		ID     int
+		Status int
+	}
+
+	requests := make(chan request)
+	responses := make(chan response)
+
+	go func() {
+		defer close(requests)
+		for _, r := range []request{{1, "/a"}, {2, "/b"}, {3, "/c"}} {
+			requests <- r
+		}
+	}()
+
+	go func() {
+		defer close(responses)
+		for _, r := range []response{{2, 200}, {1, 404}, {7, 500}} {
+			responses <- r
+		}
+	}()
+
+	joined, unmatched := Join(done, requests, responses, JoinConfig[int, request, response]{
+		Kind:           InnerJoin,
+		LeftKey:        func(r request) int { return r.ID },
+		RightKey:       func(r response) int { return r.ID },
+		WindowSize:     100,
+		WindowDuration: time.Minute,
+		EmitUnmatched:  true,
+	})
+
+	// both channels must be read, so the unmatched values are drained in a separate goroutine
+	unmatchedDone := make(chan any)
+	go func() {
+		defer close(unmatchedDone)
+		for u := range unmatched {
+			if u.FromLeft {
+				fmt.Printf("request without response: %+v\n", u.Left)
+			} else {
+				fmt.Printf("response without request: %+v\n", u.Right)
+			}
+		}
+	}()
+
+	for j := range joined {
+		fmt.Printf("%s -> %d\n", j.Left.Path, j.Right.Status)
+	}
+	<-unmatchedDone
+}
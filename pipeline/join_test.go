@@ -0,0 +1,108 @@
+package pipeline_test
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/pipeline"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJoin_Kinds(t *testing.T) {
+	tests := []struct {
+		kind      pipeline.JoinKind
+		joined    string
+		unmatched string
+	}{
+		{pipeline.InnerJoin, "[{2 2 true} {3 3 true}]", "[{1 0 true} {0 4 false}]"},
+		{pipeline.LeftJoin, "[{2 2 true} {3 3 true} {1 0 false}]", "[{0 4 false}]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind.String(), func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			left, right := make(chan int), make(chan int)
+			identity := func(v int) int { return v }
+
+			joined, unmatched := pipeline.Join(done, left, right, pipeline.JoinConfig[int, int, int]{
+				Kind:          tt.kind,
+				LeftKey:       identity,
+				RightKey:      identity,
+				WindowSize:    3,
+				EmitUnmatched: true,
+			})
+
+			// all left values arrive before the right ones, so the order of the results is deterministic
+			go func() {
+				defer close(left)
+				defer close(right)
+				for _, v := range []int{1, 2, 3} {
+					left <- v
+				}
+				for _, v := range []int{2, 3, 4} {
+					right <- v
+				}
+			}()
+
+			var gotUnmatched []pipeline.Unmatched[int, int]
+			unmatchedDone := make(chan any)
+			go func() {
+				defer close(unmatchedDone)
+				for u := range unmatched {
+					gotUnmatched = append(gotUnmatched, u)
+				}
+			}()
+
+			var gotJoined []pipeline.Joined[int, int]
+			for j := range joined {
+				gotJoined = append(gotJoined, j)
+			}
+			<-unmatchedDone
+
+			if fmt.Sprint(gotJoined) != tt.joined {
+				t.Errorf("got joined %v, want %v", gotJoined, tt.joined)
+			}
+			if fmt.Sprint(gotUnmatched) != tt.unmatched {
+				t.Errorf("got unmatched %v, want %v", gotUnmatched, tt.unmatched)
+			}
+		})
+	}
+}
+
+func TestJoin_WindowDuration(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	clk := clock.NewFake(time.Now())
+	left, right := make(chan int), make(chan int)
+	identity := func(v int) int { return v }
+
+	joined, unmatched := pipeline.Join(done, left, right, pipeline.JoinConfig[int, int, int]{
+		LeftKey:        identity,
+		RightKey:       identity,
+		WindowDuration: time.Minute,
+		EmitUnmatched:  true,
+	}, pipeline.WithClock(clk))
+
+	left <- 1
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+
+	// the left value expired, so the right value with the same key arrives too late to match
+	if u := <-unmatched; !u.FromLeft || u.Left != 1 {
+		t.Fatalf("got %+v, want the expired left value", u)
+	}
+
+	right <- 1
+	close(left)
+	close(right)
+
+	if u := <-unmatched; u.FromLeft || u.Right != 1 {
+		t.Fatalf("got %+v, want the flushed right value", u)
+	}
+	if j, ok := <-joined; ok {
+		t.Errorf("got %+v, want no joined values", j)
+	}
+}
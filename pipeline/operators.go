@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"fmt"
+)
+
+// the stages in this file are the everyday operators of a stream. They are generic, so they work on the typed
+// streams of Generator and MultiplyChannel as well as on the <-chan any streams of Repeat and Take.
+
+// WithMaxDistinct limits the memory of Distinct to the last n distinct values
+func WithMaxDistinct(n int) Option {
+	return func(o *options) { o.maxDistinct = n }
+}
+
+// operate runs the goroutine skeleton shared by the stages in this file
+//
+// it calls fn for every value of valueStream until done is closed; fn sends its results with send, and returns
+// false to stop the stage. Once valueStream is closed, end is called (if not nil) to send what's left.
+func operate[T, R any](
+	done <-chan any,
+	stage string,
+	valueStream <-chan T,
+	fn func(v T, send func(R) bool) bool,
+	end func(send func(R) bool),
+) <-chan R {
+	outStream := make(chan R)
+
+	go func() {
+		defer close(outStream)
+		defer panic_recovery.Recover(stage, panic_recovery.SendTo(done, outStream))
+
+		send := func(r R) bool {
+			select {
+			case <-done:
+				return false
+			case outStream <- r:
+				return true
+			}
+		}
+
+		for {
+			select {
+			case <-done:
+				return
+			case v, ok := <-valueStream:
+				if !ok {
+					if end != nil {
+						end(send)
+					}
+					return
+				}
+				if !fn(v, send) {
+					return
+				}
+			}
+		}
+	}()
+
+	return outStream
+}
+
+// Filter forwards the values for which keep returns true
+func Filter[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	keep func(T) bool,
+) <-chan T {
+	return operate(done, "Filter", valueStream, func(v T, send func(T) bool) bool {
+		return !keep(v) || send(v)
+	}, nil)
+}
+
+// Skip drops the first num values and forwards the rest
+func Skip[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	num int,
+) <-chan T {
+	return operate(done, "Skip", valueStream, func(v T, send func(T) bool) bool {
+		if num > 0 {
+			num--
+			return true
+		}
+		return send(v)
+	}, nil)
+}
+
+// TakeWhile forwards values as long as keep returns true, and closes its channel at the first value for which it doesn't
+func TakeWhile[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	keep func(T) bool,
+) <-chan T {
+	return operate(done, "TakeWhile", valueStream, func(v T, send func(T) bool) bool {
+		return keep(v) && send(v)
+	}, nil)
+}
+
+// SkipWhile drops values as long as skip returns true, and forwards all values from the first one for which it doesn't
+func SkipWhile[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	skip func(T) bool,
+) <-chan T {
+	skipping := true
+
+	return operate(done, "SkipWhile", valueStream, func(v T, send func(T) bool) bool {
+		if skipping && skip(v) {
+			return true
+		}
+		skipping = false
+		return send(v)
+	}, nil)
+}
+
+// Distinct forwards every value the first time it's seen
+//
+// Distinct has to remember every value it forwarded, so on an endless stream its memory grows without bound.
+// With WithMaxDistinct(n), it only remembers the last n distinct values it forwarded; a value forgotten that way
+// is forwarded again the next time it's seen.
+func Distinct[T comparable](
+	done <-chan any,
+	valueStream <-chan T,
+	opts ...Option,
+) <-chan T {
+	o := newOptions(opts...)
+
+	seen := make(map[T]struct{})
+	var order []T // the values in seen, in the order they were forwarded; only kept if the memory is limited
+
+	return operate(done, "Distinct", valueStream, func(v T, send func(T) bool) bool {
+		if _, ok := seen[v]; ok {
+			return true
+		}
+
+		seen[v] = struct{}{}
+		if o.maxDistinct > 0 {
+			order = append(order, v)
+			if len(order) > o.maxDistinct {
+				delete(seen, order[0])
+				order = order[1:]
+			}
+		}
+
+		return send(v)
+	}, nil)
+}
+
+// DistinctUntilChanged drops every value that is equal to the one before it
+func DistinctUntilChanged[T comparable](
+	done <-chan any,
+	valueStream <-chan T,
+) <-chan T {
+	var last T
+	first := true
+
+	return operate(done, "DistinctUntilChanged", valueStream, func(v T, send func(T) bool) bool {
+		if !first && v == last {
+			return true
+		}
+		first, last = false, v
+		return send(v)
+	}, nil)
+}
+
+// Scan is a running fold: it sends the accumulator after combining it with every value of valueStream
+func Scan[T, R any](
+	done <-chan any,
+	valueStream <-chan T,
+	initial R,
+	fn func(acc R, v T) R,
+) <-chan R {
+	acc := initial
+
+	return operate(done, "Scan", valueStream, func(v T, send func(R) bool) bool {
+		acc = fn(acc, v)
+		return send(acc)
+	}, nil)
+}
+
+// Reduce folds all values of valueStream into the accumulator, and sends it once valueStream is closed
+//
+// if done is closed first, Reduce closes its channel without sending anything.
+func Reduce[T, R any](
+	done <-chan any,
+	valueStream <-chan T,
+	initial R,
+	fn func(acc R, v T) R,
+) <-chan R {
+	acc := initial
+
+	return operate(done, "Reduce", valueStream, func(v T, _ func(R) bool) bool {
+		acc = fn(acc, v)
+		return true
+	}, func(send func(R) bool) {
+		send(acc)
+	})
+}
+
+// ChannelProcessingExec6 sums the squares of the distinct even numbers of a stream, and prints the running sum
+func ChannelProcessingExec6() {
+	done := make(chan any)
+	defer close(done)
+
+	isEven := func(i int) bool { return i%2 == 0 }
+	sum := func(acc, i int) int { return acc + i*i }
+
+	evens := Distinct(done, Filter(done, Generator(done, 1, 2, 2, 3, 4, 4, 6, 2), isEven))
+	for s := range Scan(done, evens, 0, sum) {
+		fmt.Println(s)
+	}
+}
@@ -0,0 +1,143 @@
+package pipeline_test
+
+import (
+	"concurrency-patterns/pipeline"
+	"fmt"
+	"testing"
+)
+
+func TestOperators(t *testing.T) {
+	isEven := func(i int) bool { return i%2 == 0 }
+	lessThan := func(n int) func(int) bool { return func(i int) bool { return i < n } }
+	sum := func(acc, i int) int { return acc + i }
+
+	tests := []struct {
+		name  string
+		stage func(done <-chan any, intStream <-chan int) <-chan int
+		in    []int
+		want  []int
+	}{
+		{
+			name: "Filter",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.Filter(done, intStream, isEven)
+			},
+			in:   []int{1, 2, 3, 4, 5, 6},
+			want: []int{2, 4, 6},
+		},
+		{
+			name: "Skip",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.Skip(done, intStream, 2)
+			},
+			in:   []int{1, 2, 3, 4},
+			want: []int{3, 4},
+		},
+		{
+			name: "Skip more than available",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.Skip(done, intStream, 10)
+			},
+			in:   []int{1, 2, 3},
+			want: nil,
+		},
+		{
+			name: "TakeWhile",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.TakeWhile(done, intStream, lessThan(3))
+			},
+			in:   []int{1, 2, 3, 1, 2},
+			want: []int{1, 2},
+		},
+		{
+			name: "SkipWhile",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.SkipWhile(done, intStream, lessThan(3))
+			},
+			in:   []int{1, 2, 3, 1, 2},
+			want: []int{3, 1, 2},
+		},
+		{
+			name: "Distinct",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.Distinct(done, intStream)
+			},
+			in:   []int{1, 2, 1, 3, 2, 1},
+			want: []int{1, 2, 3},
+		},
+		{
+			name: "Distinct with bounded memory",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.Distinct(done, intStream, pipeline.WithMaxDistinct(2))
+			},
+			in:   []int{1, 2, 1, 3, 2, 1},
+			want: []int{1, 2, 3, 1},
+		},
+		{
+			name: "DistinctUntilChanged",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.DistinctUntilChanged(done, intStream)
+			},
+			in:   []int{0, 0, 1, 1, 1, 0, 2, 2},
+			want: []int{0, 1, 0, 2},
+		},
+		{
+			name: "Scan",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.Scan(done, intStream, 0, sum)
+			},
+			in:   []int{1, 2, 3, 4},
+			want: []int{1, 3, 6, 10},
+		},
+		{
+			name: "Reduce",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.Reduce(done, intStream, 0, sum)
+			},
+			in:   []int{1, 2, 3, 4},
+			want: []int{10},
+		},
+		{
+			name: "Reduce of an empty stream",
+			stage: func(done <-chan any, intStream <-chan int) <-chan int {
+				return pipeline.Reduce(done, intStream, 42, sum)
+			},
+			in:   nil,
+			want: []int{42},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			var got []int
+			for v := range tt.stage(done, pipeline.Generator(done, tt.in...)) {
+				got = append(got, v)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperators_Done(t *testing.T) {
+	done := make(chan any)
+
+	// Repeat never ends, so the stages only stop because done is closed
+	ones := pipeline.ToInt(done, pipeline.Repeat(done, 1))
+	sums := pipeline.Scan(done, pipeline.Filter(done, ones, func(int) bool { return true }), 0, func(acc, i int) int { return acc + i })
+	reduced := pipeline.Reduce(done, ones, 0, func(acc, i int) int { return acc + i })
+
+	<-sums
+	close(done)
+
+	for range sums {
+	}
+	if v, ok := <-reduced; ok {
+		t.Errorf("got %d from Reduce, want no value after done is closed", v)
+	}
+}
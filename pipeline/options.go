@@ -0,0 +1,27 @@
+package pipeline
+
+import "concurrency-patterns/clock"
+
+// Option configures an optional behaviour of a stage
+type Option func(*options)
+
+type options struct {
+	spiller        Spiller
+	maxDistinct    int
+	discardOnClose bool
+	deadLetter     chan<- error
+	clock          clock.Clock
+}
+
+func newOptions(opts ...Option) options {
+	o := options{clock: clock.Real{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClock sets the clock used by time-based stages; tests pass a *clock.Fake to control time
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) { o.clock = clock.OrReal(clk) }
+}
@@ -0,0 +1,31 @@
+package pipeline
+
+// Result couples a value with the error that may have occurred while producing it
+//
+// like error_handling.Result, it allows a stage to send its errors downstream through the same channel as its values,
+// so the consumer - which has more context about the running program - decides what to do with them.
+type Result[T any] struct {
+	Value T
+	Error error
+}
+
+// CarryError returns a Result with err, so a stage sending Results delivers its panics (see panic_recovery.SendTo)
+func (r Result[T]) CarryError(err error) any {
+	return Result[T]{Error: err}
+}
+
+// Apply applies fn to every value of valueStream, one after another, and sends each outcome downstream as a Result
+//
+// stage names the stage in a recovered panic. It's the skeleton of the Stage functions of retry, circuit_breaker and
+// bulkhead, which only differ in how they call fn.
+func Apply[T, R any](
+	done <-chan any,
+	stage string,
+	valueStream <-chan T,
+	fn func(T) (R, error),
+) <-chan Result[R] {
+	return operate(done, stage, valueStream, func(v T, send func(Result[R]) bool) bool {
+		r, err := fn(v)
+		return send(Result[R]{Value: r, Error: err})
+	}, nil)
+}
@@ -0,0 +1,225 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the sinks in this file end a pipeline, so it doesn't need a consumer loop of its own. A sink reads until its
+// upstream is closed or done is closed, and then returns. The sinks that write return the number of values written
+// and the first error; they stop at that error, so the caller should close done to release the upstream stages.
+// Writes are buffered, and flushed before a sink returns - whether the upstream was closed or done was.
+
+// Encoding turns a value into one record, including its line ending
+type Encoding[T any] func(v T) ([]byte, error)
+
+// Line encodes a value as formatted by fmt.Println
+func Line[T any](v T) ([]byte, error) {
+	return []byte(fmt.Sprintln(v)), nil
+}
+
+// JSONLine encodes a value as a line of JSON
+func JSONLine[T any](v T) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// CSVLine encodes a record as a line of CSV
+func CSVLine(record []string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// CollectSlice reads all values of valueStream into a slice
+func CollectSlice[T any](
+	done <-chan any,
+	valueStream <-chan T,
+) []T {
+	var values []T
+	for {
+		select {
+		case <-done:
+			return values
+		case v, ok := <-valueStream:
+			if !ok {
+				return values
+			}
+			values = append(values, v)
+		}
+	}
+}
+
+// CollectMap reads all values of valueStream into a map, with the keys and values returned by kv;
+// a later value replaces an earlier one with the same key
+func CollectMap[T any, K comparable, V any](
+	done <-chan any,
+	valueStream <-chan T,
+	kv func(T) (K, V),
+) map[K]V {
+	values := make(map[K]V)
+	for {
+		select {
+		case <-done:
+			return values
+		case v, ok := <-valueStream:
+			if !ok {
+				return values
+			}
+			key, value := kv(v)
+			values[key] = value
+		}
+	}
+}
+
+// WriteTo writes the values of valueStream to w, encoded by encode
+//
+// the writes are buffered, and n counts the values that were handed to the buffer. If flushing the buffer fails,
+// err reports it, but the values that were still buffered are included in n although they never reached w.
+func WriteTo[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	w io.Writer,
+	encode Encoding[T],
+) (n int, err error) {
+	bw := bufio.NewWriter(w)
+	defer func() {
+		if flushErr := bw.Flush(); err == nil && flushErr != nil {
+			err = fmt.Errorf("pipeline: WriteTo: %w", flushErr)
+		}
+	}()
+
+	return write(done, "WriteTo", valueStream, encode, func(record []byte) error {
+		_, err := bw.Write(record)
+		return err
+	})
+}
+
+// WriteRotating writes the values of valueStream to files next to path, encoded by encode
+//
+// once a file would grow beyond maxBytes, WriteRotating continues with the next one. The files are numbered:
+// writing to "out/orders.jsonl" creates "out/orders-000000.jsonl", "out/orders-000001.jsonl", and so on.
+// A record is never split across files, so a single record larger than maxBytes gets a file of its own.
+// Like WriteTo, n counts the values handed to the buffer of the current file, including those a failed flush lost.
+func WriteRotating[T any](
+	done <-chan any,
+	valueStream <-chan T,
+	path string,
+	maxBytes int64,
+	encode Encoding[T],
+) (n int, err error) {
+	rw := &rotatingWriter{path: path, maxBytes: maxBytes}
+	defer func() {
+		if closeErr := rw.close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("pipeline: WriteRotating: %w", closeErr)
+		}
+	}()
+
+	return write(done, "WriteRotating", valueStream, encode, rw.write)
+}
+
+// write implements the writing sinks: it encodes every value of valueStream, and passes the record to writeRecord
+func write[T any](
+	done <-chan any,
+	sink string,
+	valueStream <-chan T,
+	encode Encoding[T],
+	writeRecord func(record []byte) error,
+) (int, error) {
+	var n int
+	for {
+		select {
+		case <-done:
+			return n, nil
+		case v, ok := <-valueStream:
+			if !ok {
+				return n, nil
+			}
+
+			record, err := encode(v)
+			if err != nil {
+				return n, fmt.Errorf("pipeline: %s: encoding %v: %w", sink, v, err)
+			}
+			if err := writeRecord(record); err != nil {
+				return n, fmt.Errorf("pipeline: %s: %w", sink, err)
+			}
+			n++
+		}
+	}
+}
+
+// rotatingWriter writes records to a numbered sequence of buffered files
+type rotatingWriter struct {
+	path     string
+	maxBytes int64
+	index    int
+	file     *os.File
+	buf      *bufio.Writer
+	size     int64
+}
+
+func (w *rotatingWriter) write(record []byte) error {
+	if w.file != nil && w.size > 0 && w.size+int64(len(record)) > w.maxBytes {
+		if err := w.close(); err != nil {
+			return err
+		}
+		w.index++
+	}
+
+	if w.file == nil {
+		ext := filepath.Ext(w.path)
+		name := fmt.Sprintf("%s-%06d%s", strings.TrimSuffix(w.path, ext), w.index, ext)
+
+		file, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		w.file, w.buf, w.size = file, bufio.NewWriter(file), 0
+	}
+
+	_, err := w.buf.Write(record)
+	w.size += int64(len(record))
+	return err
+}
+
+// close flushes and closes the current file, if there is one
+func (w *rotatingWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.buf.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file, w.buf = nil, nil
+
+	return err
+}
+
+// ChannelProcessingExec9 writes the multiples of 7 below 50 to stdout, one per line
+func ChannelProcessingExec9() {
+	done := make(chan any)
+	defer close(done)
+
+	multiples := Filter(done, Range(done, 1, 50, 1), func(i int) bool { return i%7 == 0 })
+
+	n, err := WriteTo(done, multiples, os.Stdout, Line[int])
+	fmt.Printf("wrote %d values, error: %v\n", n, err)
+}
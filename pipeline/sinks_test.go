@@ -0,0 +1,142 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"concurrency-patterns/pipeline"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	type point struct{ X, Y int }
+
+	tests := []struct {
+		name  string
+		write func(done <-chan any, w *bytes.Buffer) (int, error)
+		want  string
+	}{
+		{
+			name: "Line",
+			write: func(done <-chan any, w *bytes.Buffer) (int, error) {
+				return pipeline.WriteTo(done, pipeline.Range(done, 0, 3, 1), w, pipeline.Line[int])
+			},
+			want: "0\n1\n2\n",
+		},
+		{
+			name: "JSONLine",
+			write: func(done <-chan any, w *bytes.Buffer) (int, error) {
+				points := make(chan point, 2)
+				points <- point{1, 2}
+				points <- point{3, 4}
+				close(points)
+				return pipeline.WriteTo(done, points, w, pipeline.JSONLine[point])
+			},
+			want: "{\"X\":1,\"Y\":2}\n{\"X\":3,\"Y\":4}\n",
+		},
+		{
+			name: "CSVLine",
+			write: func(done <-chan any, w *bytes.Buffer) (int, error) {
+				records := make(chan []string, 2)
+				records <- []string{"a", "b,c"}
+				records <- []string{"d"}
+				close(records)
+				return pipeline.WriteTo(done, records, w, pipeline.CSVLine)
+			},
+			want: "a,\"b,c\"\nd\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			var buf bytes.Buffer
+			n, err := tt.write(done, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+			if want := strings.Count(tt.want, "\n"); n != want {
+				t.Errorf("got %d values written, want %d", n, want)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriteTo_Error(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	// the error only shows when the buffer is flushed: n counts the buffered values, and err reports that they were lost
+	n, err := pipeline.WriteTo(done, pipeline.Range(done, 0, 3, 1), failingWriter{}, pipeline.Line[int])
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("got %v, want the error of the writer", err)
+	}
+	if n != 3 {
+		t.Errorf("got %d values written, want 3", n)
+	}
+}
+
+func TestWriteRotating(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	dir := t.TempDir()
+	n, err := pipeline.WriteRotating(done, pipeline.Range(done, 0, 12, 1), filepath.Join(dir, "out.txt"), 6, pipeline.Line[int])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 12 {
+		t.Errorf("got %d values written, want 12", n)
+	}
+
+	// every file holds three records of two bytes, except for the last one, where the records are three bytes long
+	want := map[string]string{
+		"out-000000.txt": "0\n1\n2\n",
+		"out-000001.txt": "3\n4\n5\n",
+		"out-000002.txt": "6\n7\n8\n",
+		"out-000003.txt": "9\n10\n",
+		"out-000004.txt": "11\n",
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Errorf("got %d files, want %d", len(entries), len(want))
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s: got %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	if got := pipeline.CollectSlice(done, pipeline.Range(done, 0, 3, 1)); len(got) != 3 || got[2] != 2 {
+		t.Errorf("got %v, want [0 1 2]", got)
+	}
+
+	squares := pipeline.CollectMap(done, pipeline.Range(done, 0, 4, 1), func(i int) (int, int) { return i, i * i })
+	if len(squares) != 4 || squares[3] != 9 {
+		t.Errorf("got %v, want the squares of 0 to 3", squares)
+	}
+}
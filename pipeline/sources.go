@@ -0,0 +1,270 @@
+package pipeline
+
+import (
+	"bufio"
+	"concurrency-patterns/panic_recovery"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Generator converts a slice into a stream, but batch jobs rarely start from hard-coded slices.
+// The sources in this file start a pipeline from files, readers, ranges and tickers. Every source closes its channel
+// when its input is exhausted or done is closed, and a source that reads sends its read errors downstream as Results,
+// so the consumer decides whether to skip a bad record or give up.
+
+// maxLineSize is the length of the longest line Lines and JSONLines read
+//
+// bufio.Scanner stops at 64 KiB by default, which a single JSON record easily exceeds.
+const maxLineSize = 16 << 20
+
+// newLineScanner returns a Scanner for the lines of r that accepts lines of up to maxLineSize
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	return scanner
+}
+
+// Lines sends the lines of r, without their line endings
+//
+// a line longer than 16 MiB ends the stream with an error wrapping bufio.ErrTooLong.
+func Lines(
+	done <-chan any,
+	r io.Reader,
+) <-chan Result[string] {
+	lineStream := make(chan Result[string])
+
+	go func() {
+		defer close(lineStream)
+		defer panic_recovery.Recover("Lines", panic_recovery.SendTo(done, lineStream))
+
+		scanner := newLineScanner(r)
+		for scanner.Scan() {
+			if !sendValue(done, lineStream, Result[string]{Value: scanner.Text()}) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			sendValue(done, lineStream, Result[string]{Error: fmt.Errorf("pipeline: Lines: %w", err)})
+		}
+	}()
+
+	return lineStream
+}
+
+// CSVRecords sends the records of the CSV data read from r
+//
+// a malformed record is sent as an error, and reading continues with the next record; any other error ends the stream.
+func CSVRecords(
+	done <-chan any,
+	r io.Reader,
+) <-chan Result[[]string] {
+	recordStream := make(chan Result[[]string])
+
+	go func() {
+		defer close(recordStream)
+		defer panic_recovery.Recover("CSVRecords", panic_recovery.SendTo(done, recordStream))
+
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			var parseErr *csv.ParseError
+			switch {
+			case errors.As(err, &parseErr):
+				if !sendValue(done, recordStream, Result[[]string]{Error: fmt.Errorf("pipeline: CSVRecords: %w", err)}) {
+					return
+				}
+			case err != nil:
+				sendValue(done, recordStream, Result[[]string]{Error: fmt.Errorf("pipeline: CSVRecords: %w", err)})
+				return
+			default:
+				if !sendValue(done, recordStream, Result[[]string]{Value: record}) {
+					return
+				}
+			}
+		}
+	}()
+
+	return recordStream
+}
+
+// JSONLines decodes every non-empty line of r into a T
+//
+// a line that can't be decoded is sent as an error, and reading continues with the next line.
+// A line longer than 16 MiB ends the stream with an error wrapping bufio.ErrTooLong.
+func JSONLines[T any](
+	done <-chan any,
+	r io.Reader,
+) <-chan Result[T] {
+	valueStream := make(chan Result[T])
+
+	go func() {
+		defer close(valueStream)
+		defer panic_recovery.Recover("JSONLines", panic_recovery.SendTo(done, valueStream))
+
+		scanner := newLineScanner(r)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+
+			var result Result[T]
+			if err := json.Unmarshal(scanner.Bytes(), &result.Value); err != nil {
+				result = Result[T]{Error: fmt.Errorf("pipeline: JSONLines: line %d: %w", line, err)}
+			}
+
+			if !sendValue(done, valueStream, result) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			sendValue(done, valueStream, Result[T]{Error: fmt.Errorf("pipeline: JSONLines: %w", err)})
+		}
+	}()
+
+	return valueStream
+}
+
+// WalkDir sends the paths of the regular files in the tree rooted at root, in lexical order
+//
+// a directory that can't be read is sent as an error, and the walk continues with the rest of the tree.
+func WalkDir(
+	done <-chan any,
+	root string,
+) <-chan Result[string] {
+	pathStream := make(chan Result[string])
+
+	go func() {
+		defer close(pathStream)
+		defer panic_recovery.Recover("WalkDir", panic_recovery.SendTo(done, pathStream))
+
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			var result Result[string]
+			switch {
+			case err != nil:
+				result.Error = fmt.Errorf("pipeline: WalkDir: %w", err)
+			case d.Type().IsRegular():
+				result.Value = path
+			default:
+				return nil
+			}
+
+			if !sendValue(done, pathStream, result) {
+				return filepath.SkipAll
+			}
+			return nil
+		})
+	}()
+
+	return pathStream
+}
+
+// Range sends the integers from start up to, but not including, stop, incremented by step
+//
+// step may be negative to count down; Range panics if step is 0.
+func Range(
+	done <-chan any,
+	start, stop, step int,
+) <-chan int {
+	if step == 0 {
+		panic("pipeline: zero step for Range")
+	}
+
+	intStream := make(chan int)
+
+	go func() {
+		defer close(intStream)
+		defer panic_recovery.Recover("Range", panic_recovery.SendTo(done, intStream))
+
+		for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+			if !sendValue(done, intStream, i) {
+				return
+			}
+
+			// stop before i += step would pass stop - near the limits of int, it would overflow instead.
+			// The distances are compared as uints, which can't overflow: the distance between two ints fits into a uint.
+			if (step > 0 && uint(stop-i) <= uint(step)) || (step < 0 && uint(i-stop) <= uint(-step)) {
+				return
+			}
+		}
+	}()
+
+	return intStream
+}
+
+// Tick calls fn on every tick of a ticker with the given interval, and sends its result
+//
+// like RepeatFn, Tick only stops when done is closed; put Take or TakeWithin behind it to make it finite.
+// If the consumer falls behind, ticks are dropped rather than queued, just like with time.Ticker.
+func Tick[T any](
+	done <-chan any,
+	interval time.Duration,
+	fn func(t time.Time) T,
+	opts ...Option,
+) <-chan T {
+	o := newOptions(opts...)
+	valueStream := make(chan T)
+
+	go func() {
+		defer close(valueStream)
+		defer panic_recovery.Recover("Tick", panic_recovery.SendTo(done, valueStream))
+
+		ticker := o.clock.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case t := <-ticker.C():
+				if !sendValue(done, valueStream, fn(t)) {
+					return
+				}
+			}
+		}
+	}()
+
+	return valueStream
+}
+
+// ChannelProcessingExec8 sums the amounts of the orders read from JSON lines, and reports the lines it could not read
+func ChannelProcessingExec8() {
+	done := make(chan any)
+	defer close(done)
+
+	type order struct {
+		ID     int `json:"id"`
+		Amount int `json:"amount"`
+	}
+
+	input := strings.NewReader(`{"id": 1, "amount": 20}
+{"id": 2, "amount": 22}
+not json
+{"id": 3, "amount": 100}
+`)
+
+	var total int
+	for result := range JSONLines[order](done, input) {
+		if result.Error != nil {
+			fmt.Println(result.Error)
+			continue
+		}
+		total += result.Value.Amount
+	}
+
+	fmt.Println("total:", total)
+}
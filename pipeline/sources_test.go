@@ -0,0 +1,222 @@
+package pipeline_test
+
+import (
+	"bufio"
+	"concurrency-patterns/panic_recovery"
+	"concurrency-patterns/pipeline"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// panickingReader panics on every read
+type panickingReader struct{}
+
+func (panickingReader) Read([]byte) (int, error) { panic("boom") }
+
+// collect reads all Results of a source, and returns the values and the errors separately
+func collect[T any](results <-chan pipeline.Result[T]) (values []T, errs []error) {
+	for result := range results {
+		if result.Error != nil {
+			errs = append(errs, result.Error)
+			continue
+		}
+		values = append(values, result.Value)
+	}
+	return values, errs
+}
+
+func TestSources(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.txt", "a/c.txt", "a/b/d.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type point struct{ X, Y int }
+
+	tests := []struct {
+		name   string
+		source func(done <-chan any) (values any, errs int)
+		want   string
+		errs   int
+	}{
+		{
+			name: "Lines",
+			source: func(done <-chan any) (any, int) {
+				values, errs := collect(pipeline.Lines(done, strings.NewReader("a\r\nb\n\nc")))
+				return values, len(errs)
+			},
+			want: "[a b  c]",
+		},
+		{
+			name: "Lines beyond the default Scanner buffer",
+			source: func(done <-chan any) (any, int) {
+				values, errs := collect(pipeline.Lines(done, strings.NewReader(strings.Repeat("a", 1<<20)+"\nb")))
+				lengths := make([]int, len(values))
+				for i, v := range values {
+					lengths[i] = len(v)
+				}
+				return lengths, len(errs)
+			},
+			want: "[1048576 1]",
+		},
+		{
+			name: "Lines too long",
+			source: func(done <-chan any) (any, int) {
+				values, errs := collect(pipeline.Lines(done, strings.NewReader("a\n"+strings.Repeat("b", 17<<20))))
+				if len(errs) != 1 || !errors.Is(errs[0], bufio.ErrTooLong) {
+					return errs, len(errs)
+				}
+				return values, len(errs)
+			},
+			want: "[a]",
+			errs: 1,
+		},
+		{
+			name: "CSVRecords",
+			source: func(done <-chan any) (any, int) {
+				values, errs := collect(pipeline.CSVRecords(done, strings.NewReader("a,b\nc,\"d\ne\"\nf,g\"h\ni\n")))
+				return values, len(errs)
+			},
+			want: "[[a b] [c d\ne] [i]]",
+			errs: 1,
+		},
+		{
+			name: "JSONLines",
+			source: func(done <-chan any) (any, int) {
+				values, errs := collect(pipeline.JSONLines[point](done, strings.NewReader("{\"X\":1,\"Y\":2}\n\n{\"X\":\n{\"X\":3}\n")))
+				return values, len(errs)
+			},
+			want: "[{1 2} {3 0}]",
+			errs: 1,
+		},
+		{
+			name: "JSONLines beyond the default Scanner buffer",
+			source: func(done <-chan any) (any, int) {
+				values, errs := collect(pipeline.JSONLines[string](done, strings.NewReader(`"`+strings.Repeat("a", 1<<20)+`"`)))
+				lengths := make([]int, len(values))
+				for i, v := range values {
+					lengths[i] = len(v)
+				}
+				return lengths, len(errs)
+			},
+			want: "[1048576]",
+		},
+		{
+			name: "WalkDir",
+			source: func(done <-chan any) (any, int) {
+				values, errs := collect(pipeline.WalkDir(done, dir))
+				for i, v := range values {
+					values[i], _ = filepath.Rel(dir, v)
+				}
+				return values, len(errs)
+			},
+			want: fmt.Sprint([]string{filepath.Join("a", "b", "d.txt"), filepath.Join("a", "c.txt"), "b.txt"}),
+		},
+		{
+			name: "WalkDir of a missing directory",
+			source: func(done <-chan any) (any, int) {
+				values, errs := collect(pipeline.WalkDir(done, filepath.Join(dir, "missing")))
+				return values, len(errs)
+			},
+			want: "[]",
+			errs: 1,
+		},
+		{
+			name: "Range",
+			source: func(done <-chan any) (any, int) {
+				var values []int
+				for i := range pipeline.Range(done, 0, 10, 3) {
+					values = append(values, i)
+				}
+				return values, 0
+			},
+			want: "[0 3 6 9]",
+		},
+		{
+			name: "Range up to the largest int",
+			source: func(done <-chan any) (any, int) {
+				var values []int
+				for i := range pipeline.Range(done, math.MaxInt-3, math.MaxInt, 2) {
+					values = append(values, i-math.MaxInt)
+				}
+				return values, 0
+			},
+			want: "[-3 -1]",
+		},
+		{
+			name: "Range down to the smallest int",
+			source: func(done <-chan any) (any, int) {
+				var values []int
+				for i := range pipeline.Range(done, math.MinInt+2, math.MinInt, -2) {
+					values = append(values, i-math.MinInt)
+				}
+				return values, 0
+			},
+			want: "[2]",
+		},
+		{
+			name: "Range over all ints",
+			source: func(done <-chan any) (any, int) {
+				var values []int
+				for i := range pipeline.Range(done, math.MinInt, math.MaxInt, math.MaxInt) {
+					values = append(values, i)
+				}
+				return values, 0
+			},
+			want: fmt.Sprint([]int{math.MinInt, -1, math.MaxInt - 1}),
+		},
+		{
+			name: "Lines of a panicking reader",
+			source: func(done <-chan any) (any, int) {
+				panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+				defer panic_recovery.SetSupervisor(nil)
+
+				values, errs := collect(pipeline.Lines(done, panickingReader{}))
+				var panicErr *panic_recovery.PanicError
+				if len(errs) != 1 || !errors.As(errs[0], &panicErr) {
+					return errs, len(errs)
+				}
+				return values, len(errs)
+			},
+			want: "[]",
+			errs: 1,
+		},
+		{
+			name: "Range counting down",
+			source: func(done <-chan any) (any, int) {
+				var values []int
+				for i := range pipeline.Range(done, 3, 0, -1) {
+					values = append(values, i)
+				}
+				return values, 0
+			},
+			want: "[3 2 1]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			values, errs := tt.source(done)
+			if fmt.Sprint(values) != tt.want {
+				t.Errorf("got %q, want %q", fmt.Sprint(values), tt.want)
+			}
+			if errs != tt.errs {
+				t.Errorf("got %d errors, want %d", errs, tt.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/panic_recovery"
+	"fmt"
+	"time"
+)
+
+// TimeoutError is sent downstream by a stage that gave up waiting for its upstream
+//
+// it flows through the pipeline like any other value, so the consumer at the end of the pipeline can decide
+// whether to degrade (e.g. use what it got so far) or fail. Like net.Error, it reports Timeout() == true.
+type TimeoutError struct {
+	Stage   string
+	After   time.Duration
+	PerItem bool // true if the stage waited too long for a single value, false if the whole stream took too long
+}
+
+func (e *TimeoutError) Error() string {
+	if e.PerItem {
+		return fmt.Sprintf("pipeline: %s: no value received within %v", e.Stage, e.After)
+	}
+	return fmt.Sprintf("pipeline: %s: stream did not complete within %v", e.Stage, e.After)
+}
+
+// Timeout always returns true, so callers can check for timeouts with interface{ Timeout() bool }
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// TimeoutConfig configures the Timeout stage; a zero duration disables the respective timeout
+//
+// the timeouts are part of the stage's signature rather than Options, since no other stage would honour them.
+// Stages such as Take, bridge_channel.Bridge and tee_channel.Tee wait on their upstream without a bound:
+// put Timeout behind them to bound the wait.
+type TimeoutConfig struct {
+	Item   time.Duration // how long the stage waits for the next value from its upstream
+	Stream time.Duration // how long the stage waits for its upstream in total
+}
+
+// Timeout forwards the values of valueStream until one of the configured timeouts expires
+//
+// when a timeout expires, Timeout sends a *TimeoutError downstream and closes its channel.
+// Since bridge_channel.Bridge, tee_channel.Tee and the stages in this package all produce <-chan any,
+// Timeout can be put behind any of them so a stalled upstream degrades the pipeline instead of hanging it.
+func Timeout(
+	done <-chan any,
+	valueStream <-chan any,
+	cfg TimeoutConfig,
+	opts ...Option,
+) <-chan any {
+	return timeout(done, "Timeout", valueStream, -1, cfg, newOptions(opts...))
+}
+
+// TakeWithin reads up to num values from valueStream, but gives up once d has elapsed
+//
+// if d elapses first, TakeWithin sends a *TimeoutError instead of the missing values.
+// Unlike Take, TakeWithin also stops when valueStream is closed early.
+func TakeWithin(
+	done <-chan any,
+	valueStream <-chan any,
+	num int,
+	d time.Duration,
+	opts ...Option,
+) <-chan any {
+	return timeout(done, "TakeWithin", valueStream, num, TimeoutConfig{Stream: d}, newOptions(opts...))
+}
+
+// timeout implements Timeout and TakeWithin; a negative num means the number of values is not limited
+func timeout(
+	done <-chan any,
+	stage string,
+	valueStream <-chan any,
+	num int,
+	cfg TimeoutConfig,
+	o options,
+) <-chan any {
+	outStream := make(chan any)
+
+	go func() {
+		defer close(outStream)
+		defer panic_recovery.Recover(stage, panic_recovery.SendTo(done, outStream))
+
+		// a nil channel blocks forever, so timeouts that are not configured never fire
+		var itemTimeout, streamTimeout <-chan time.Time
+
+		if cfg.Stream > 0 {
+			streamTimer := o.clock.NewTimer(cfg.Stream)
+			defer streamTimer.Stop()
+			streamTimeout = streamTimer.C()
+		}
+
+		var itemTimer clock.Timer
+		if cfg.Item > 0 {
+			itemTimer = o.clock.NewTimer(cfg.Item)
+			defer itemTimer.Stop()
+			itemTimeout = itemTimer.C()
+		}
+
+		sendErr := func(err error) {
+			select {
+			case <-done:
+			case outStream <- err:
+			}
+		}
+
+		for i := 0; num < 0 || i < num; i++ {
+			var v any
+			var ok bool
+
+			select {
+			case <-done:
+				return
+			case <-streamTimeout:
+				sendErr(&TimeoutError{Stage: stage, After: cfg.Stream})
+				return
+			case <-itemTimeout:
+				sendErr(&TimeoutError{Stage: stage, After: cfg.Item, PerItem: true})
+				return
+			case v, ok = <-valueStream:
+				if !ok {
+					return
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case outStream <- v:
+			}
+
+			// the per-item timeout only measures the wait for the upstream, not the wait for a slow consumer;
+			// a tick that fired while the value was being sent is dropped by Reset
+			if itemTimer != nil {
+				itemTimer.Reset(cfg.Item)
+			}
+		}
+	}()
+
+	return outStream
+}
+
+// TakeWithin degrades gracefully if the upstream is too slow
+func ChannelProcessingExec5() {
+	done := make(chan any)
+	defer close(done)
+
+	slow := func() any {
+		time.Sleep(300 * time.Millisecond)
+		return "tick"
+	}
+
+	for v := range TakeWithin(done, RepeatFn(done, slow), 10, time.Second) {
+		if err, ok := v.(*TimeoutError); ok {
+			fmt.Println("giving up:", err)
+			break
+		}
+		fmt.Println(v)
+	}
+}
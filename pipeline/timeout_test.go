@@ -0,0 +1,147 @@
+package pipeline_test
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/pipeline"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeout_SlowConsumer(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	clk := clock.NewFake(time.Now())
+
+	// the upstream has all its values ready, only the consumer is slow
+	valueStream := make(chan any, 3)
+	for i := 0; i < 3; i++ {
+		valueStream <- i
+	}
+	close(valueStream)
+
+	timedOut := pipeline.Timeout(done, valueStream, pipeline.TimeoutConfig{Item: time.Second}, pipeline.WithClock(clk))
+
+	<-timedOut
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+
+	for v := range timedOut {
+		if err, ok := v.(error); ok {
+			t.Fatalf("got %v, want no timeout while the consumer is slow", err)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		stage   func(done <-chan any, valueStream <-chan any, clk clock.Clock) <-chan any
+		send    int  // values the upstream sends before it stalls
+		close   bool // whether the upstream is closed after sending, instead of stalling
+		want    int  // values expected downstream
+		perItem bool // the expected timeout, if any
+		timeout bool
+	}{
+		{
+			name: "per-item timeout",
+			stage: func(done <-chan any, valueStream <-chan any, clk clock.Clock) <-chan any {
+				return pipeline.Timeout(done, valueStream, pipeline.TimeoutConfig{Item: time.Second}, pipeline.WithClock(clk))
+			},
+			send: 2, want: 2, timeout: true, perItem: true,
+		},
+		{
+			name: "stream timeout",
+			stage: func(done <-chan any, valueStream <-chan any, clk clock.Clock) <-chan any {
+				return pipeline.Timeout(done, valueStream, pipeline.TimeoutConfig{Stream: time.Second}, pipeline.WithClock(clk))
+			},
+			send: 2, want: 2, timeout: true,
+		},
+		{
+			name: "early close",
+			stage: func(done <-chan any, valueStream <-chan any, clk clock.Clock) <-chan any {
+				return pipeline.Timeout(done, valueStream, pipeline.TimeoutConfig{Item: time.Second, Stream: time.Minute}, pipeline.WithClock(clk))
+			},
+			send: 2, close: true, want: 2,
+		},
+		{
+			name: "TakeWithin reaches num",
+			stage: func(done <-chan any, valueStream <-chan any, clk clock.Clock) <-chan any {
+				return pipeline.TakeWithin(done, valueStream, 3, time.Second, pipeline.WithClock(clk))
+			},
+			send: 5, want: 3,
+		},
+		{
+			name: "TakeWithin times out",
+			stage: func(done <-chan any, valueStream <-chan any, clk clock.Clock) <-chan any {
+				return pipeline.TakeWithin(done, valueStream, 3, time.Second, pipeline.WithClock(clk))
+			},
+			send: 2, want: 2, timeout: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			clk := clock.NewFake(time.Now())
+
+			// the upstream never closes unless told to, so the stage can only end by reaching num or timing out
+			valueStream := make(chan any, tt.send)
+			for i := 0; i < tt.send; i++ {
+				valueStream <- i
+			}
+			if tt.close {
+				close(valueStream)
+			}
+
+			stage := tt.stage(done, valueStream, clk)
+
+			var values int
+			var timeoutErr *pipeline.TimeoutError
+			for values < tt.want {
+				if _, ok := (<-stage).(int); !ok {
+					t.Fatalf("got a non-value after %d values, want %d values", values, tt.want)
+				}
+				values++
+			}
+
+			// the stage resets its per-item timer after it sent a value, so the test can't tell when the timer is due;
+			// it keeps advancing the clock until the stage gives up
+			for {
+				var tick <-chan time.Time
+				if tt.timeout {
+					tick = time.After(time.Millisecond)
+				}
+
+				var v any
+				var ok bool
+				select {
+				case <-tick:
+					clk.Advance(time.Second)
+					continue
+				case v, ok = <-stage:
+				}
+				if !ok {
+					break
+				}
+
+				err, isErr := v.(error)
+				if !isErr || !errors.As(err, &timeoutErr) {
+					t.Fatalf("got %v, want a *TimeoutError or the end of the stream", v)
+				}
+			}
+
+			switch {
+			case !tt.timeout && timeoutErr != nil:
+				t.Errorf("got %v, want no timeout", timeoutErr)
+			case tt.timeout && timeoutErr == nil:
+				t.Error("got no timeout")
+			case tt.timeout && (timeoutErr.PerItem != tt.perItem || !timeoutErr.Timeout()):
+				t.Errorf("got %+v, want a timeout with PerItem == %v", timeoutErr, tt.perItem)
+			}
+		})
+	}
+}
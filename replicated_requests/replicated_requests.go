@@ -0,0 +1,257 @@
+package replicated_requests
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/fan_out_fan_in"
+	"concurrency-patterns/or_channel"
+	"concurrency-patterns/panic_recovery"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ErrNoReplicas is returned when FirstResponse is called without any handler
+var ErrNoReplicas = errors.New("replicated requests: no replicas given")
+
+// ErrCanceled is returned when the parent done channel was closed before any replica succeeded
+var ErrCanceled = errors.New("replicated requests: canceled before any replica responded")
+
+// Handler performs one replica of a request.
+//
+// a handler must stop working and return as soon as done is closed,
+// otherwise the losing replicas can't be cancelled once a winner is found
+type Handler func(done <-chan any) (any, error)
+
+// Attempt describes how a single replica fared
+type Attempt struct {
+	Replica  int
+	Latency  time.Duration
+	Error    error
+	Canceled bool // true if the replica was stopped (or never reported) because another replica won
+}
+
+// Result is the outcome of a replicated request
+//
+// Winner is the index of the handler whose response was taken, or -1 if no replica succeeded.
+// Attempts contains one entry per handler, indexed by replica.
+type Result struct {
+	Value    any
+	Error    error
+	Winner   int
+	Latency  time.Duration
+	Attempts []Attempt
+}
+
+// Latencies returns the sorted latencies of all replicas that completed without being cancelled
+func (r Result) Latencies() []time.Duration {
+	latencies := make([]time.Duration, 0, len(r.Attempts))
+	for _, a := range r.Attempts {
+		if !a.Canceled {
+			latencies = append(latencies, a.Latency)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	return latencies
+}
+
+// Percentile returns the p-th percentile (0 <= p <= 100) of Latencies, or 0 if no replica completed
+func (r Result) Percentile(p float64) time.Duration {
+	latencies := r.Latencies()
+	if len(latencies) == 0 {
+		return 0
+	}
+
+	i := int(p / 100 * float64(len(latencies)-1))
+	i = max(0, min(i, len(latencies)-1))
+
+	return latencies[i]
+}
+
+// outcome is what each replica sends back to FirstResponse
+type outcome struct {
+	Attempt
+	value any
+}
+
+// FirstResponse issues the same request to all handlers concurrently and returns the first successful response
+//
+// every replica runs with a done channel that is the or-channel of the parent done channel and an internal cancel channel.
+// As soon as the first replica succeeds, the cancel channel is closed, and all the remaining replicas are told to stop.
+// The outcomes of all replicas are multiplexed into a single stream with FanIn, so FirstResponse is able to wait
+// for every replica to exit - no goroutine outlives the call.
+//
+// if no replica succeeds, Result.Error joins the errors of all replicas
+func FirstResponse(done <-chan any, handlers ...Handler) Result {
+	return FirstResponseWithClock(done, clock.Real{}, handlers...)
+}
+
+// FirstResponseWithClock is FirstResponse with the clock that measures the latencies passed in;
+// tests pass a *clock.Fake to control time
+func FirstResponseWithClock(done <-chan any, clk clock.Clock, handlers ...Handler) Result {
+	clk = clock.OrReal(clk)
+
+	result := Result{Winner: -1, Attempts: make([]Attempt, len(handlers))}
+	if len(handlers) == 0 {
+		result.Error = ErrNoReplicas
+		return result
+	}
+
+	for i := range result.Attempts {
+		result.Attempts[i] = Attempt{Replica: i, Canceled: true}
+	}
+
+	cancel := make(chan any)
+	replicaDone := or_channel.Or(done, cancel)
+
+	streams := make([]<-chan any, len(handlers))
+	for i, handler := range handlers {
+		streams[i] = replica(replicaDone, clk, i, handler)
+	}
+
+	var errs []error
+	for v := range fan_out_fan_in.FanIn(done, streams...) {
+		o := v.(outcome)
+		result.Attempts[o.Replica] = o.Attempt
+
+		switch {
+		case o.Error == nil && result.Winner == -1:
+			result.Winner = o.Replica
+			result.Value = o.value
+			result.Latency = o.Latency
+
+			// tell the remaining replicas to stop
+			close(cancel)
+		case o.Error != nil && !o.Canceled:
+			errs = append(errs, fmt.Errorf("replica %d: %w", o.Replica, o.Error))
+		}
+	}
+
+	if result.Winner != -1 {
+		return result
+	}
+
+	// no winner, so cancel was never closed: release the or-channel goroutine
+	close(cancel)
+
+	result.Error = errors.Join(errs...)
+	if result.Error == nil {
+		result.Error = ErrCanceled
+	}
+
+	return result
+}
+
+// replica runs handler in its own goroutine and reports its outcome on the returned channel
+//
+// the channel is buffered, so a replica never blocks on reporting, even if nobody is reading anymore
+func replica(done <-chan any, clk clock.Clock, id int, handler Handler) <-chan any {
+	outcomeStream := make(chan any, 1)
+
+	go func() {
+		defer close(outcomeStream)
+
+		start := clk.Now()
+
+		// a panicking handler counts as a failed replica
+		defer panic_recovery.Recover("replica", func(err *panic_recovery.PanicError) {
+			outcomeStream <- outcome{Attempt: Attempt{Replica: id, Latency: clk.Since(start), Error: err}}
+		})
+
+		value, err := handler(done)
+
+		o := outcome{Attempt: Attempt{Replica: id, Latency: clk.Since(start), Error: err}, value: value}
+		if err != nil {
+			select {
+			case <-done:
+				o.Canceled = true
+			default:
+			}
+		}
+
+		outcomeStream <- o
+	}()
+
+	return outcomeStream
+}
+
+// HTTPGet returns a Handler that GETs url with client and returns the response body
+//
+// the request is cancelled when the handler's done channel is closed. Responses with a status code >= 400 are treated as errors.
+func HTTPGet(client *http.Client, url string) Handler {
+	return func(done <-chan any) (any, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// translate the done channel into context cancellation
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+
+		return body, nil
+	}
+}
+
+// ReplicatedRequestsExec replicates a simulated request to 10 handlers that take between 1 and 5 seconds
+//
+// the first handler to respond wins, and all the other handlers are cancelled
+func ReplicatedRequestsExec() {
+	doWork := func(done <-chan any) (any, error) {
+		// simulate random load
+		load := time.Duration(1+rand.Intn(5)) * time.Second
+
+		select {
+		case <-done:
+			return nil, errors.New("cancelled")
+		case <-time.After(load):
+		}
+
+		return load, nil
+	}
+
+	done := make(chan any)
+	defer close(done)
+
+	handlers := make([]Handler, 10)
+	for i := range handlers {
+		handlers[i] = doWork
+	}
+
+	result := FirstResponse(done, handlers...)
+	if result.Error != nil {
+		fmt.Printf("error: %v\n", result.Error)
+		return
+	}
+
+	fmt.Printf("replica %d won after %v\n", result.Winner, result.Latency)
+	fmt.Printf("completed latencies: %v\n", result.Latencies())
+}
@@ -0,0 +1,181 @@
+package replicated_requests_test
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/replicated_requests"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T, delay time.Duration, status int) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(r.Host))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestFirstResponse_FastestReplicaWins(t *testing.T) {
+	slow := newServer(t, 2*time.Second, http.StatusOK)
+	fast := newServer(t, 10*time.Millisecond, http.StatusOK)
+	medium := newServer(t, time.Second, http.StatusOK)
+
+	done := make(chan any)
+	defer close(done)
+
+	client := &http.Client{}
+	start := time.Now()
+	result := replicated_requests.FirstResponse(done,
+		replicated_requests.HTTPGet(client, slow.URL),
+		replicated_requests.HTTPGet(client, fast.URL),
+		replicated_requests.HTTPGet(client, medium.URL),
+	)
+
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if result.Winner != 1 {
+		t.Fatalf("expected replica 1 to win, got %d", result.Winner)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("losing replicas were not cancelled, call took %v", elapsed)
+	}
+	if got := len(result.Attempts); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+	for _, i := range []int{0, 2} {
+		if !result.Attempts[i].Canceled {
+			t.Errorf("expected replica %d to be cancelled, got %+v", i, result.Attempts[i])
+		}
+	}
+	if got := result.Latencies(); len(got) != 1 || got[0] != result.Latency {
+		t.Errorf("expected only the winner's latency, got %v", got)
+	}
+}
+
+func TestFirstResponse_Latency(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+
+	// a handler that responds after d on the fake clock
+	after := func(d time.Duration) replicated_requests.Handler {
+		return func(done <-chan any) (any, error) {
+			select {
+			case <-done:
+				return nil, errors.New("cancelled")
+			case <-clk.After(d):
+			}
+			return d, nil
+		}
+	}
+
+	done := make(chan any)
+	defer close(done)
+
+	results := make(chan replicated_requests.Result)
+	go func() {
+		results <- replicated_requests.FirstResponseWithClock(done, clk, after(5*time.Second), after(2*time.Second))
+	}()
+
+	clk.BlockUntil(2)
+	clk.Advance(2 * time.Second)
+	result := <-results
+
+	if result.Winner != 1 || result.Latency != 2*time.Second {
+		t.Fatalf("got replica %d after %v, want replica 1 after 2s", result.Winner, result.Latency)
+	}
+	if want := []time.Duration{2 * time.Second}; fmt.Sprint(result.Latencies()) != fmt.Sprint(want) {
+		t.Errorf("got latencies %v, want %v", result.Latencies(), want)
+	}
+	if !result.Attempts[0].Canceled {
+		t.Errorf("expected replica 0 to be cancelled, got %+v", result.Attempts[0])
+	}
+}
+
+func TestFirstResponse_SkipsFailingReplicas(t *testing.T) {
+	failing := newServer(t, 0, http.StatusInternalServerError)
+	healthy := newServer(t, 50*time.Millisecond, http.StatusOK)
+
+	done := make(chan any)
+	defer close(done)
+
+	client := &http.Client{}
+	result := replicated_requests.FirstResponse(done,
+		replicated_requests.HTTPGet(client, failing.URL),
+		replicated_requests.HTTPGet(client, healthy.URL),
+	)
+
+	if result.Winner != 1 {
+		t.Fatalf("expected replica 1 to win, got %d (error: %v)", result.Winner, result.Error)
+	}
+	if result.Attempts[0].Error == nil {
+		t.Errorf("expected replica 0 to report its error")
+	}
+}
+
+func TestFirstResponse_AllReplicasFail(t *testing.T) {
+	failing := newServer(t, 0, http.StatusInternalServerError)
+
+	done := make(chan any)
+	defer close(done)
+
+	client := &http.Client{}
+	result := replicated_requests.FirstResponse(done,
+		replicated_requests.HTTPGet(client, failing.URL),
+		replicated_requests.HTTPGet(client, failing.URL),
+	)
+
+	if result.Winner != -1 || result.Error == nil {
+		t.Fatalf("expected an error without winner, got winner %d, error %v", result.Winner, result.Error)
+	}
+}
+
+func TestResult_Percentile(t *testing.T) {
+	// the latencies are given out of order, and the cancelled replica's latency is ignored
+	attempts := func(latencies ...time.Duration) []replicated_requests.Attempt {
+		a := make([]replicated_requests.Attempt, 0, len(latencies)+1)
+		for i, l := range latencies {
+			a = append(a, replicated_requests.Attempt{Replica: i, Latency: l})
+		}
+		return append(a, replicated_requests.Attempt{Replica: len(latencies), Latency: time.Hour, Canceled: true})
+	}
+	five := attempts(3*time.Second, time.Second, 5*time.Second, 2*time.Second, 4*time.Second)
+
+	tests := []struct {
+		name     string
+		attempts []replicated_requests.Attempt
+		p        float64
+		want     time.Duration
+	}{
+		{"no attempts", nil, 50, 0},
+		{"only cancelled attempts", attempts(), 50, 0},
+		{"single latency", attempts(time.Second), 99, time.Second},
+		{"p0 is the minimum", five, 0, time.Second},
+		{"p50 is the median", five, 50, 3 * time.Second},
+		{"p90 rounds down", five, 90, 4 * time.Second},
+		{"p100 is the maximum", five, 100, 5 * time.Second},
+		{"below 0 is clamped", five, -10, time.Second},
+		{"above 100 is clamped", five, 150, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := replicated_requests.Result{Attempts: tt.attempts}
+			if got := result.Percentile(tt.p); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package retry
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff returns how long to wait before the next attempt
+//
+// attempt is the number of the attempt that just failed (starting at 1), prev is the previous wait (0 before the first retry).
+type Backoff func(attempt int, prev time.Duration) time.Duration
+
+// Constant waits d before every retry
+func Constant(d time.Duration) Backoff {
+	return func(int, time.Duration) time.Duration { return d }
+}
+
+// Exponential doubles the wait with every retry, starting at base and never exceeding limit
+func Exponential(base, limit time.Duration) Backoff {
+	return func(attempt int, _ time.Duration) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < limit; i++ {
+			// doubling a wait above limit/2 could overflow into a negative one
+			if d > limit/2 {
+				d = limit
+			} else {
+				d *= 2
+			}
+		}
+		return min(d, limit)
+	}
+}
+
+// DecorrelatedJitter waits a random duration between base and three times the previous wait, never exceeding limit
+//
+// the randomness spreads the retries of many clients that failed at the same time, so they don't hit the recovering
+// dependency in waves. See "Exponential Backoff And Jitter" on the AWS Architecture Blog.
+func DecorrelatedJitter(base, limit time.Duration) Backoff {
+	return func(_ int, prev time.Duration) time.Duration {
+		upper := max(prev*3, base)
+		d := base + time.Duration(rand.Int63n(int64(upper-base)+1))
+		return min(d, limit)
+	}
+}
@@ -0,0 +1,49 @@
+package retry
+
+import "sync"
+
+// Budget limits the number of retries of a whole pipeline
+//
+// without a budget, every stage retries on its own, and a failing dependency receives MaxAttempts times the usual load
+// exactly when it is least able to handle it - a retry storm. A Budget is a token bucket shared by all the stages of
+// a pipeline: every retry takes a token, and every success puts back a fraction of one. Once the bucket is down to
+// half its size, retries stop until enough calls succeed again. This is the scheme gRPC uses for retry throttling.
+type Budget struct {
+	mu        sync.Mutex
+	tokens    float64
+	maxTokens float64
+	ratio     float64
+}
+
+// NewBudget returns a full Budget of maxTokens tokens; every success puts back ratio tokens
+func NewBudget(maxTokens, ratio float64) *Budget {
+	return &Budget{tokens: maxTokens, maxTokens: maxTokens, ratio: ratio}
+}
+
+// withdraw takes a token for a retry and reports whether the retry is allowed
+func (b *Budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens <= b.maxTokens/2 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// deposit puts back tokens for a success
+func (b *Budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.tokens+b.ratio, b.maxTokens)
+}
+
+// Tokens returns the number of tokens left
+func (b *Budget) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens
+}
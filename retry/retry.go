@@ -0,0 +1,156 @@
+package retry
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/pipeline"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrBudgetExhausted is returned (wrapped together with the last error) when the Budget denied a retry
+var ErrBudgetExhausted = errors.New("retry: budget exhausted")
+
+// ErrCanceled is returned (wrapped together with the last error) when done was closed while waiting for the next attempt
+var ErrCanceled = errors.New("retry: canceled")
+
+// Policy describes when and how often to retry
+type Policy struct {
+	MaxAttempts int              // including the first attempt; values below 1 mean a single attempt
+	Backoff     Backoff          // nil means no wait between attempts
+	Retryable   func(error) bool // nil means IsRetryable
+	Budget      *Budget          // nil means no budget
+	Clock       clock.Clock      // nil means clock.Real
+}
+
+// permanentError marks an error as not retryable
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not retryable, no matter the classifier
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable is the default classifier: every error is retryable, except for cancellations and errors marked Permanent
+func IsRetryable(err error) bool {
+	var permanent *permanentError
+	return !errors.As(err, &permanent) && !errors.Is(err, context.Canceled)
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable, or the policy gives up
+//
+// waiting for the next attempt honours the done channel.
+func Do[T any](done <-chan any, p Policy, fn func() (T, error)) (T, error) {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	clk := clock.OrReal(p.Clock)
+
+	var wait time.Duration
+	for attempt := 1; ; attempt++ {
+		v, err := fn()
+		if err == nil {
+			if p.Budget != nil {
+				p.Budget.deposit()
+			}
+			return v, nil
+		}
+
+		if !retryable(err) {
+			return v, err
+		}
+		if attempt >= p.MaxAttempts {
+			return v, fmt.Errorf("retry: giving up after %d attempts: %w", attempt, err)
+		}
+		if p.Budget != nil && !p.Budget.withdraw() {
+			return v, errors.Join(ErrBudgetExhausted, err)
+		}
+
+		if p.Backoff != nil {
+			wait = p.Backoff(attempt, wait)
+		}
+
+		timer := clk.NewTimer(wait)
+		select {
+		case <-done:
+			timer.Stop()
+			return v, errors.Join(ErrCanceled, err)
+		case <-timer.C():
+		}
+	}
+}
+
+// Stage applies fn to every value of valueStream, retrying according to p
+//
+// the values are processed one after another, and the outcome of each is sent downstream as a pipeline.Result.
+func Stage[T, R any](
+	done <-chan any,
+	valueStream <-chan T,
+	p Policy,
+	fn func(T) (R, error),
+) <-chan pipeline.Result[R] {
+	return pipeline.Apply(done, "retry.Stage", valueStream, func(v T) (R, error) {
+		return Do(done, p, func() (R, error) { return fn(v) })
+	})
+}
+
+// RetryExec checks the status of a few URLs, retrying server errors with decorrelated jitter
+func RetryExec() {
+	done := make(chan any)
+	defer close(done)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	policy := Policy{
+		MaxAttempts: 4,
+		Backoff:     DecorrelatedJitter(100*time.Millisecond, 2*time.Second),
+		Budget:      NewBudget(10, 0.1),
+	}
+
+	getStatus := func(url string) (string, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= http.StatusInternalServerError:
+			return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+		case resp.StatusCode >= http.StatusBadRequest:
+			// retrying a client error won't change the outcome
+			return "", Permanent(fmt.Errorf("GET %s: %s", url, resp.Status))
+		}
+		return resp.Status, nil
+	}
+
+	urls := make(chan string)
+	go func() {
+		defer close(urls)
+		for _, url := range []string{"https://www.google.com", "https://www.badass"} {
+			select {
+			case <-done:
+				return
+			case urls <- url:
+			}
+		}
+	}()
+
+	for result := range Stage(done, urls, policy, getStatus) {
+		if result.Error != nil {
+			fmt.Printf("error: %v\n", result.Error)
+			continue
+		}
+		fmt.Printf("Response: %v\n", result.Value)
+	}
+}
@@ -0,0 +1,201 @@
+package retry_test
+
+import (
+	"concurrency-patterns/clock"
+	"concurrency-patterns/retry"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+var errFailed = errors.New("failed")
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff retry.Backoff
+		want    []time.Duration
+	}{
+		{"Constant", retry.Constant(time.Second), []time.Duration{time.Second, time.Second, time.Second}},
+		{
+			"Exponential",
+			retry.Exponential(time.Second, 10*time.Second),
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second},
+		},
+		{"Exponential with base above limit", retry.Exponential(time.Minute, time.Second), []time.Duration{time.Second, time.Second}},
+		{
+			"Exponential near the largest duration",
+			retry.Exponential(3<<61, math.MaxInt64),
+			[]time.Duration{3 << 61, math.MaxInt64, math.MaxInt64},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prev time.Duration
+			for i, want := range tt.want {
+				prev = tt.backoff(i+1, prev)
+				if prev != want {
+					t.Errorf("attempt %d: got %v, want %v", i+1, prev, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	base, limit := 10*time.Millisecond, time.Second
+	backoff := retry.DecorrelatedJitter(base, limit)
+
+	var prev time.Duration
+	for attempt := 1; attempt <= 1000; attempt++ {
+		d := backoff(attempt, prev)
+		if upper := min(max(prev*3, base), limit); d < base || d > upper {
+			t.Fatalf("attempt %d: got %v after %v, want a wait between %v and %v", attempt, d, prev, base, upper)
+		}
+		prev = d
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errFailed, true},
+		{retry.Permanent(errFailed), false},
+		{fmt.Errorf("wrapped: %w", retry.Permanent(errFailed)), false},
+		{context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		if got := retry.IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v): got %v, want %v", tt.err, got, tt.want)
+		}
+	}
+
+	if err := retry.Permanent(errFailed); !errors.Is(err, errFailed) {
+		t.Errorf("got %v, want Permanent to keep the error it wraps", err)
+	}
+	if retry.Permanent(nil) != nil {
+		t.Error("got an error from Permanent(nil), want nil")
+	}
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   retry.Policy
+		failures int // calls that fail before the first success
+		err      error
+		calls    int
+	}{
+		{"success after retries", retry.Policy{MaxAttempts: 3}, 2, nil, 3},
+		{"MaxAttempts", retry.Policy{MaxAttempts: 3}, 5, errFailed, 3},
+		{"single attempt", retry.Policy{}, 5, errFailed, 1},
+		{"not retryable", retry.Policy{MaxAttempts: 3, Retryable: func(error) bool { return false }}, 5, errFailed, 1},
+		// the budget allows retries while more than half of its 4 tokens are left
+		{"Budget", retry.Policy{MaxAttempts: 10, Budget: retry.NewBudget(4, 0.5)}, 10, retry.ErrBudgetExhausted, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan any)
+			defer close(done)
+
+			var calls int
+			v, err := retry.Do(done, tt.policy, func() (int, error) {
+				if calls++; calls <= tt.failures {
+					return 0, errFailed
+				}
+				return 42, nil
+			})
+
+			switch {
+			case tt.err == nil && (err != nil || v != 42):
+				t.Errorf("got %v, %v, want 42", v, err)
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls {
+				t.Errorf("got %d calls, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestBudget(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	budget := retry.NewBudget(4, 0.5)
+	policy := retry.Policy{MaxAttempts: 10, Budget: budget}
+
+	retry.Do(done, policy, func() (int, error) { return 0, errFailed })
+	if got := budget.Tokens(); got != 2 {
+		t.Fatalf("got %v tokens after two retries, want 2", got)
+	}
+
+	// a success puts back a fraction of a token, but never more than the budget holds
+	for i := 0; i < 5; i++ {
+		retry.Do(done, policy, func() (int, error) { return 0, nil })
+	}
+	if got := budget.Tokens(); got != 4 {
+		t.Errorf("got %v tokens after five successes, want 4", got)
+	}
+}
+
+func TestDo_Backoff(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	clk := clock.NewFake(time.Now())
+	start := clk.Now()
+	policy := retry.Policy{MaxAttempts: 4, Backoff: retry.Exponential(time.Second, time.Minute), Clock: clk}
+
+	var attempts []time.Duration
+	result := make(chan error)
+	go func() {
+		_, err := retry.Do(done, policy, func() (int, error) {
+			attempts = append(attempts, clk.Since(start))
+			return 0, errFailed
+		})
+		result <- err
+	}()
+
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		clk.BlockUntil(1)
+		clk.Advance(wait)
+	}
+
+	if err := <-result; !errors.Is(err, errFailed) {
+		t.Errorf("got %v, want the last error", err)
+	}
+	if want := "[0s 1s 3s 7s]"; fmt.Sprint(attempts) != want {
+		t.Errorf("got attempts at %v, want %v", attempts, want)
+	}
+}
+
+func TestDo_Canceled(t *testing.T) {
+	done := make(chan any)
+
+	clk := clock.NewFake(time.Now())
+	policy := retry.Policy{MaxAttempts: 3, Backoff: retry.Constant(time.Hour), Clock: clk}
+
+	result := make(chan error)
+	go func() {
+		_, err := retry.Do(done, policy, func() (int, error) { return 0, errFailed })
+		result <- err
+	}()
+
+	clk.BlockUntil(1)
+	close(done)
+
+	err := <-result
+	if !errors.Is(err, retry.ErrCanceled) || !errors.Is(err, errFailed) {
+		t.Errorf("got %v, want ErrCanceled together with the last error", err)
+	}
+}
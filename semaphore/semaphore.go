@@ -0,0 +1,212 @@
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+// a semaphore bounds the number of goroutines that work on something at the same time,
+// without fixing the number of goroutines up front.
+//
+// Acquire blocks until there is room, but - like every blocking operation in this module - it also selects on a done channel,
+// so a goroutine waiting for the semaphore can always be cancelled.
+
+// ErrCanceled is returned if done was closed (or the context was cancelled) before the semaphore could be acquired
+var ErrCanceled = errors.New("semaphore: canceled")
+
+// Semaphore is a counting semaphore built on a buffered channel
+//
+// every acquired slot is an element in the channel. Goroutines blocked on sending to a full channel are queued by
+// the runtime and served first-in first-out, so waiting goroutines acquire the semaphore in the order they arrived.
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// New returns a Semaphore that allows n concurrent holders
+func New(n int) *Semaphore {
+	return &Semaphore{slots: make(chan struct{}, max(n, 1))}
+}
+
+// Acquire blocks until a slot is free or done is closed
+func (s *Semaphore) Acquire(done <-chan any) error {
+	// a select picks randomly among ready cases, so without the check a closed done could still acquire a free slot
+	select {
+	case <-done:
+		return ErrCanceled
+	default:
+	}
+
+	select {
+	case <-done:
+		return ErrCanceled
+	case s.slots <- struct{}{}:
+		return nil
+	}
+}
+
+// AcquireContext blocks until a slot is free or ctx is done
+func (s *Semaphore) AcquireContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Join(ErrCanceled, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return errors.Join(ErrCanceled, ctx.Err())
+	case s.slots <- struct{}{}:
+		return nil
+	}
+}
+
+// TryAcquire acquires a slot if one is free, without blocking
+func (s *Semaphore) TryAcquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees a slot; releasing a slot that was not acquired panics
+func (s *Semaphore) Release() {
+	select {
+	case <-s.slots:
+	default:
+		panic("semaphore: released more than acquired")
+	}
+}
+
+// Weighted is a semaphore whose holders acquire different amounts of its capacity
+//
+// it is fair: waiters are served strictly in the order they arrived. A waiter that asks for a large weight is not
+// overtaken by later waiters that ask for less, even if those would fit - otherwise it could starve forever.
+type Weighted struct {
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	waiters list.List // of *waiter
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{} // closed once the weight was acquired on behalf of the waiter
+}
+
+// NewWeighted returns a Weighted semaphore with a capacity of n
+func NewWeighted(n int64) *Weighted {
+	return &Weighted{size: n}
+}
+
+// Acquire blocks until n can be acquired or done is closed
+//
+// asking for more than the capacity of the semaphore blocks until done is closed.
+func (s *Weighted) Acquire(done <-chan any, n int64) error {
+	return s.acquire(done, nil, n)
+}
+
+// AcquireContext blocks until n can be acquired or ctx is done
+func (s *Weighted) AcquireContext(ctx context.Context, n int64) error {
+	if err := s.acquire(nil, ctx.Done(), n); err != nil {
+		return errors.Join(err, ctx.Err())
+	}
+	return nil
+}
+
+// acquire selects on whichever of the done channels is set; a nil channel blocks forever
+func (s *Weighted) acquire(done <-chan any, ctxDone <-chan struct{}, n int64) error {
+	// a caller that is already cancelled must not acquire, even if the weight is free
+	select {
+	case <-done:
+		return ErrCanceled
+	case <-ctxDone:
+		return ErrCanceled
+	default:
+	}
+
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	w := &waiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	cancel := func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		select {
+		case <-w.ready:
+			// acquired while we were being cancelled: the cancellation wins, give the weight back
+			s.cur -= n
+			s.notifyWaiters()
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// if we were blocking the front of the queue, the waiters behind us may fit now
+			if isFront && s.size > s.cur {
+				s.notifyWaiters()
+			}
+		}
+		return ErrCanceled
+	}
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-done:
+		return cancel()
+	case <-ctxDone:
+		return cancel()
+	}
+}
+
+// TryAcquire acquires n if it is available and nobody is waiting, without blocking
+func (s *Weighted) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release gives back n; releasing more than was acquired panics
+func (s *Weighted) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than acquired")
+	}
+	s.notifyWaiters()
+}
+
+// notifyWaiters hands the free capacity to the waiters at the front of the queue, in order
+func (s *Weighted) notifyWaiters() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+
+		w := front.Value.(*waiter)
+		if s.size-s.cur < w.n {
+			// strict FIFO: stop at the first waiter that doesn't fit
+			return
+		}
+
+		s.cur += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,178 @@
+package semaphore_test
+
+import (
+	"concurrency-patterns/semaphore"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphore_BoundsConcurrency(t *testing.T) {
+	const limit = 3
+	sem := semaphore.New(limit)
+
+	done := make(chan any)
+	defer close(done)
+
+	var running, maxRunning atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sem.Acquire(done); err != nil {
+				t.Errorf("acquire: %v", err)
+				return
+			}
+			defer sem.Release()
+
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := maxRunning.Load(); got > limit {
+		t.Fatalf("expected at most %d concurrent holders, got %d", limit, got)
+	}
+}
+
+func TestSemaphore_AcquireHonoursDone(t *testing.T) {
+	sem := semaphore.New(1)
+	if !sem.TryAcquire() {
+		t.Fatalf("expected TryAcquire to succeed on an empty semaphore")
+	}
+	if sem.TryAcquire() {
+		t.Fatalf("expected TryAcquire to fail on a full semaphore")
+	}
+
+	done := make(chan any)
+	result := make(chan error)
+	go func() { result <- sem.Acquire(done) }()
+
+	close(done)
+	if err := <-result; !errors.Is(err, semaphore.ErrCanceled) {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := sem.AcquireContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+}
+
+func TestAcquire_AlreadyCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan any)
+	close(done)
+
+	// both semaphores have room, but a cancelled caller must never acquire
+	sem := semaphore.New(1)
+	weighted := semaphore.NewWeighted(1)
+	for i := 0; i < 100; i++ {
+		if err := sem.Acquire(done); !errors.Is(err, semaphore.ErrCanceled) {
+			t.Fatalf("Semaphore.Acquire: expected ErrCanceled, got %v", err)
+		}
+		if err := sem.AcquireContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Semaphore.AcquireContext: expected the context error, got %v", err)
+		}
+		if err := weighted.Acquire(done, 1); !errors.Is(err, semaphore.ErrCanceled) {
+			t.Fatalf("Weighted.Acquire: expected ErrCanceled, got %v", err)
+		}
+		if err := weighted.AcquireContext(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Weighted.AcquireContext: expected the context error, got %v", err)
+		}
+	}
+
+	if !sem.TryAcquire() || !weighted.TryAcquire(1) {
+		t.Errorf("expected the cancelled calls to leave the semaphores empty")
+	}
+}
+
+func TestWeighted_FIFO(t *testing.T) {
+	sem := semaphore.NewWeighted(10)
+
+	done := make(chan any)
+	defer close(done)
+
+	if err := sem.Acquire(done, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	// a large waiter arrives first, a small one second; the small one would fit, but must not overtake
+	order := make(chan int64, 2)
+	large := make(chan struct{})
+	go func() {
+		close(large)
+		sem.Acquire(done, 10)
+		order <- 10
+		sem.Release(10)
+	}()
+	<-large
+	for sem.TryAcquire(1) {
+		// TryAcquire only succeeds until the large waiter is queued
+		sem.Release(1)
+	}
+
+	go func() {
+		sem.Acquire(done, 2)
+		order <- 2
+		sem.Release(2)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case n := <-order:
+		t.Fatalf("waiter %d acquired while the semaphore was held", n)
+	default:
+	}
+
+	sem.Release(8)
+	if first, second := <-order, <-order; first != 10 || second != 2 {
+		t.Fatalf("expected the waiters in arrival order 10, 2, got %d, %d", first, second)
+	}
+}
+
+func TestWeighted_CancelUnblocksQueue(t *testing.T) {
+	sem := semaphore.NewWeighted(4)
+
+	done := make(chan any)
+	defer close(done)
+	sem.Acquire(done, 3)
+
+	// the front waiter asks for more than is left and gives up; the waiter behind it fits and must proceed
+	cancelFront := make(chan any)
+	frontErr := make(chan error)
+	go func() { frontErr <- sem.Acquire(cancelFront, 4) }()
+	time.Sleep(10 * time.Millisecond)
+
+	acquired := make(chan struct{})
+	go func() {
+		sem.Acquire(done, 1)
+		close(acquired)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	close(cancelFront)
+	if err := <-frontErr; !errors.Is(err, semaphore.ErrCanceled) {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("waiter behind the cancelled one was not woken up")
+	}
+}
@@ -0,0 +1,64 @@
+package spill_queue
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+)
+
+// Codec converts the values of a stream into records and back
+type Codec interface {
+	Encode(v any) ([]byte, error)
+	Decode(data []byte) (any, error)
+}
+
+// GobCodec encodes values with encoding/gob
+//
+// gob needs to know the concrete types stored in an interface value: basic types work out of the box,
+// custom types must be registered with gob.Register.
+// Every record is a self-contained gob stream, so records can be decoded in any order after a restart.
+type GobCodec struct{}
+
+func (GobCodec) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Decode(data []byte) (any, error) {
+	var v any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// JSONCodec encodes values with encoding/json
+//
+// JSON does not record the type of a value. If New is nil, records are decoded into an any,
+// so numbers come back as float64 and structs as map[string]any. Otherwise, New must return a pointer
+// to decode into, and Decode returns the value it points to.
+type JSONCodec struct {
+	New func() any
+}
+
+func (JSONCodec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c JSONCodec) Decode(data []byte) (any, error) {
+	if c.New == nil {
+		var v any
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+
+	ptr := c.New()
+	if err := json.Unmarshal(data, ptr); err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(ptr).Elem().Interface(), nil
+}
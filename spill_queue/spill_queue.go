@@ -0,0 +1,490 @@
+package spill_queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Queue stores the values of a stream in append-only segment files on disk
+//
+// every value is written as a record: a 4 byte length, a 4 byte CRC32 checksum and the encoded value.
+// Values are appended to the last segment; once it exceeds the segment size, a new segment is started.
+// Values are read from the first segment, and once a segment is fully consumed, it is deleted.
+//
+// the read position (the cursor) is persisted when a segment is deleted, on Sync and on Close.
+// After a crash, Open replays every value after the last persisted cursor, so consumers see each value at least once.
+// A record that was only partially written when the process died is detected by its length or checksum and discarded.
+//
+// Queue implements pipeline.Spiller, so it can back a pipeline.Buffer stage with the SpillToDisk policy.
+type Queue struct {
+	mu sync.Mutex
+
+	dir         string
+	codec       Codec
+	segmentSize int64
+
+	segments []uint64 // ids of the segments on disk; the first is read from, the last is appended to
+
+	w     *os.File
+	wSize int64
+
+	r    *os.File
+	rOff int64
+
+	len int
+
+	// the record at the cursor, once it has been read by Peek
+	peeked   bool
+	next     any
+	nextSize int64
+}
+
+const (
+	headerSize = 8
+	segmentExt = ".seg"
+	cursorName = "cursor"
+
+	// DefaultSegmentSize is the size after which a new segment is started
+	DefaultSegmentSize = 4 << 20
+
+	// MaxRecordSize is the largest encoded value a record can hold; a header with a larger length is corrupt
+	MaxRecordSize = 64 << 20
+)
+
+var (
+	// ErrCorrupt is returned if a record in the middle of a segment can't be read
+	ErrCorrupt = errors.New("spill queue: corrupt record")
+
+	// ErrTooLarge is returned by Push if the encoded value is larger than MaxRecordSize
+	ErrTooLarge = errors.New("spill queue: value too large")
+)
+
+// Option configures a Queue
+type Option func(*Queue)
+
+// WithSegmentSize sets the size in bytes after which a new segment is started
+func WithSegmentSize(size int64) Option {
+	return func(q *Queue) { q.segmentSize = size }
+}
+
+// Open opens the queue stored in dir, creating dir if necessary
+//
+// values left over from a previous run are replayed first.
+func Open(dir string, codec Codec, opts ...Option) (*Queue, error) {
+	q := &Queue{dir: dir, codec: codec, segmentSize: DefaultSegmentSize}
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if err := q.recover(); err != nil {
+		q.closeFiles()
+		return nil, fmt.Errorf("spill queue: open %s: %w", dir, err)
+	}
+
+	return q, nil
+}
+
+// recover restores the state of the queue from the segments and the cursor on disk
+func (q *Queue) recover() error {
+	ids, err := q.listSegments()
+	if err != nil {
+		return err
+	}
+
+	cursorID, cursorOff, err := q.readCursor()
+	if err != nil {
+		return err
+	}
+
+	// segments before the cursor were consumed, but the process died before they were deleted
+	for len(ids) > 0 && ids[0] < cursorID {
+		if err := os.Remove(q.segmentPath(ids[0])); err != nil {
+			return err
+		}
+		ids = ids[1:]
+	}
+	if len(ids) == 0 || ids[0] != cursorID {
+		cursorOff = 0
+	}
+
+	if len(ids) == 0 {
+		ids = []uint64{max(cursorID, 1)}
+		f, err := os.Create(q.segmentPath(ids[0]))
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+	q.segments = ids
+
+	// count the values left to read and cut off a torn record at the end of the last segment
+	for i, id := range ids {
+		off := int64(0)
+		if i == 0 {
+			off = cursorOff
+		}
+
+		n, end, err := q.scan(id, off)
+		if err != nil && (!errors.Is(err, ErrCorrupt) || i != len(ids)-1) {
+			return err
+		}
+		if err != nil {
+			if err := os.Truncate(q.segmentPath(id), end); err != nil {
+				return err
+			}
+		}
+		q.len += n
+	}
+
+	if q.w, err = os.OpenFile(q.segmentPath(ids[len(ids)-1]), os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return err
+	}
+	info, err := q.w.Stat()
+	if err != nil {
+		return err
+	}
+	q.wSize = info.Size()
+
+	if q.r, err = os.Open(q.segmentPath(ids[0])); err != nil {
+		return err
+	}
+	if _, err := q.r.Seek(cursorOff, io.SeekStart); err != nil {
+		return err
+	}
+	q.rOff = cursorOff
+
+	return nil
+}
+
+// scan counts the records in segment id starting at off, and returns the offset after the last valid record
+func (q *Queue) scan(id uint64, off int64) (int, int64, error) {
+	f, err := os.Open(q.segmentPath(id))
+	if err != nil {
+		return 0, off, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return 0, off, err
+	}
+
+	n := 0
+	for {
+		data, err := readRecord(f)
+		if err == io.EOF {
+			return n, off, nil
+		}
+		if err != nil {
+			return n, off, err
+		}
+		n++
+		off += headerSize + int64(len(data))
+	}
+}
+
+// Push appends v to the queue
+func (q *Queue) Push(v any) error {
+	data, err := q.codec.Encode(v)
+	if err != nil {
+		return fmt.Errorf("spill queue: encode %T: %w", v, err)
+	}
+	if len(data) > MaxRecordSize {
+		return fmt.Errorf("%w: %T encodes to %d bytes", ErrTooLarge, v, len(data))
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.w == nil {
+		return os.ErrClosed
+	}
+
+	size := headerSize + int64(len(data))
+	if q.wSize > 0 && q.wSize+size > q.segmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, size)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[headerSize:], data)
+
+	if _, err := q.w.Write(record); err != nil {
+		return err
+	}
+	q.wSize += size
+	q.len++
+
+	return nil
+}
+
+// rotate starts a new segment to append to
+func (q *Queue) rotate() error {
+	id := q.segments[len(q.segments)-1] + 1
+
+	w, err := os.OpenFile(q.segmentPath(id), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	q.w.Close()
+
+	q.w = w
+	q.wSize = 0
+	q.segments = append(q.segments, id)
+
+	return nil
+}
+
+// Peek returns the next value without removing it from the queue
+func (q *Queue) Peek() (any, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.peek()
+}
+
+func (q *Queue) peek() (any, bool, error) {
+	if q.r == nil {
+		return nil, false, os.ErrClosed
+	}
+	if q.peeked {
+		return q.next, true, nil
+	}
+	if q.len == 0 {
+		return nil, false, nil
+	}
+
+	for {
+		data, err := readRecord(q.r)
+		if err == io.EOF && len(q.segments) > 1 {
+			if err := q.nextSegment(); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		v, err := q.codec.Decode(data)
+		if err != nil {
+			return nil, false, fmt.Errorf("spill queue: decode: %w", err)
+		}
+
+		q.peeked = true
+		q.next = v
+		q.nextSize = headerSize + int64(len(data))
+
+		return v, true, nil
+	}
+}
+
+// nextSegment deletes the fully consumed read segment and continues with the next one
+//
+// the cursor is persisted before the segment is deleted, so a crash in between only leaves a file behind
+// that is removed by the next Open.
+func (q *Queue) nextSegment() error {
+	consumed := q.segments[0]
+
+	r, err := os.Open(q.segmentPath(q.segments[1]))
+	if err != nil {
+		return err
+	}
+	q.r.Close()
+
+	q.r = r
+	q.rOff = 0
+	q.segments = q.segments[1:]
+
+	if err := q.writeCursor(); err != nil {
+		return err
+	}
+	return os.Remove(q.segmentPath(consumed))
+}
+
+// Pop removes the next value from the queue and returns it
+func (q *Queue) Pop() (any, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	v, ok, err := q.peek()
+	if !ok || err != nil {
+		return v, ok, err
+	}
+
+	q.rOff += q.nextSize
+	q.len--
+	q.peeked = false
+	q.next = nil
+
+	return v, true, nil
+}
+
+// Len returns the number of values in the queue
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.len
+}
+
+// Sync flushes the appended values to stable storage and persists the cursor
+func (q *Queue) Sync() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.w == nil {
+		return os.ErrClosed
+	}
+	if err := q.w.Sync(); err != nil {
+		return err
+	}
+	return q.writeCursor()
+}
+
+// Close persists the cursor and closes the segment files; the values left in the queue are kept on disk
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.w == nil {
+		return os.ErrClosed
+	}
+
+	err := errors.Join(q.w.Sync(), q.writeCursor())
+	q.closeFiles()
+
+	return err
+}
+
+func (q *Queue) closeFiles() {
+	if q.w != nil {
+		q.w.Close()
+		q.w = nil
+	}
+	if q.r != nil {
+		q.r.Close()
+		q.r = nil
+	}
+}
+
+func (q *Queue) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+func (q *Queue) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, nil
+}
+
+// readCursor returns the persisted read position, or the start of the first segment if there is none
+func (q *Queue) readCursor() (uint64, int64, error) {
+	data, err := os.ReadFile(filepath.Join(q.dir, cursorName))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var id uint64
+	var off int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &id, &off); err != nil {
+		return 0, 0, fmt.Errorf("read cursor: %w", err)
+	}
+
+	return id, off, nil
+}
+
+// writeCursor persists the read position atomically by writing a temporary file and renaming it
+func (q *Queue) writeCursor() error {
+	path := filepath.Join(q.dir, cursorName)
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d %d\n", q.segments[0], q.rOff); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// readRecord reads one record from r
+//
+// it returns io.EOF if r is at the end of a segment, and ErrCorrupt if the record is incomplete, too large or its checksum is wrong
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrCorrupt
+		}
+		return nil, err
+	}
+
+	// the length is checked before it is allocated: a torn or garbled header may claim up to 4GiB
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > MaxRecordSize {
+		return nil, ErrCorrupt
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrCorrupt
+		}
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, ErrCorrupt
+	}
+
+	return data, nil
+}
@@ -0,0 +1,163 @@
+package spill_queue_test
+
+import (
+	"concurrency-patterns/pipeline"
+	"concurrency-patterns/spill_queue"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openQueue(t *testing.T, dir string, codec spill_queue.Codec) *spill_queue.Queue {
+	t.Helper()
+
+	q, err := spill_queue.Open(dir, codec, spill_queue.WithSegmentSize(64))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return q
+}
+
+func TestQueue_OrderAcrossSegments(t *testing.T) {
+	for name, codec := range map[string]spill_queue.Codec{
+		"gob":  spill_queue.GobCodec{},
+		"json": spill_queue.JSONCodec{New: func() any { return new(int) }},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			q := openQueue(t, dir, codec)
+			defer q.Close()
+
+			for i := 0; i < 100; i++ {
+				if err := q.Push(i); err != nil {
+					t.Fatalf("push: %v", err)
+				}
+			}
+
+			for i := 0; i < 100; i++ {
+				v, ok, err := q.Pop()
+				if err != nil || !ok || v != i {
+					t.Fatalf("pop %d: got %v, %v, %v", i, v, ok, err)
+				}
+			}
+
+			if _, ok, _ := q.Pop(); ok {
+				t.Fatalf("expected queue to be empty")
+			}
+
+			segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+			if len(segments) != 1 {
+				t.Errorf("expected consumed segments to be removed, got %v", segments)
+			}
+		})
+	}
+}
+
+func TestQueue_ResumeAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, spill_queue.GobCodec{})
+
+	for i := 0; i < 50; i++ {
+		q.Push(i)
+	}
+	for i := 0; i < 10; i++ {
+		q.Pop()
+	}
+	if err := q.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		q.Pop()
+	}
+
+	// simulate a crash: the queue is never closed, and the last record was only partially written
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	last, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last.Write([]byte{0, 0, 0, 42, 1, 2})
+	last.Close()
+
+	q = openQueue(t, dir, spill_queue.GobCodec{})
+	defer q.Close()
+
+	// everything after the last persisted cursor is replayed: at-least-once.
+	// The cursor was synced after 10 values and may have moved further when a consumed segment was deleted
+	first, _, _ := q.Pop()
+	if first.(int) < 10 || first.(int) > 20 {
+		t.Fatalf("expected to resume between 10 and 20, got %v", first)
+	}
+	if got, want := q.Len(), 49-first.(int); got != want {
+		t.Fatalf("expected %d values after restart, got %d", want, got)
+	}
+
+	// the torn record was cut off, so appending continues to work
+	q.Push(50)
+	for i := first.(int) + 1; i <= 50; i++ {
+		if v, _, err := q.Pop(); v != i {
+			t.Fatalf("expected %d, got %v (%v)", i, v, err)
+		}
+	}
+}
+
+func TestQueue_OversizedHeader(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, spill_queue.GobCodec{})
+
+	for i := 0; i < 3; i++ {
+		q.Push(i)
+	}
+
+	// a garbled header that claims a 4GiB record is rejected before the record is allocated, and cut off like a torn one
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	last, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1, 2, 3})
+	last.Close()
+
+	q = openQueue(t, dir, spill_queue.GobCodec{})
+	defer q.Close()
+
+	if got := q.Len(); got != 3 {
+		t.Fatalf("expected 3 values after restart, got %d", got)
+	}
+	for i := 0; i < 3; i++ {
+		if v, _, err := q.Pop(); v != i {
+			t.Fatalf("expected %d, got %v (%v)", i, v, err)
+		}
+	}
+}
+
+func TestStage_ResumeAfterCancel(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, spill_queue.GobCodec{})
+
+	done := make(chan any)
+	queued := spill_queue.Stage(done, pipeline.Take(done, pipeline.Repeat(done, 1), 30), q)
+
+	// consume a few values, then cancel the pipeline while the rest is waiting on disk
+	for i := 0; i < 5; i++ {
+		<-queued
+	}
+	// wait for the upstream to be drained into the queue
+	for deadline := time.Now().Add(time.Second); q.Len() < 25; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 25 values in the queue, got %d", q.Len())
+		}
+	}
+	close(done)
+	for range queued {
+	}
+	q.Close()
+
+	q = openQueue(t, dir, spill_queue.GobCodec{})
+	defer q.Close()
+
+	if got := q.Len(); got != 25 {
+		t.Fatalf("expected 25 undelivered values after restart, got %d", got)
+	}
+}
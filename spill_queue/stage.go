@@ -0,0 +1,102 @@
+package spill_queue
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"concurrency-patterns/pipeline"
+	"fmt"
+	"os"
+)
+
+// Stage decouples the upstream from the downstream with a Queue
+//
+// Stage reads from valueStream as fast as values arrive and appends them to q, and it sends the values of q downstream
+// as fast as the downstream is able to take them - so a downstream that falls behind never blocks the upstream,
+// and the values that pile up live on disk instead of in memory.
+//
+// like Generator and Take, Stage stops when done is closed. A value is only removed from q once it has been
+// sent downstream, and the cursor is persisted when Stage exits, so a queue reopened after a restart continues
+// with the first value that was not delivered. The caller owns q and closes it after the returned channel is closed.
+// If q fails, the error is sent downstream and the stage stops.
+func Stage(
+	done <-chan any,
+	valueStream <-chan any,
+	q *Queue,
+) <-chan any {
+	queuedStream := make(chan any)
+
+	go func() {
+		defer close(queuedStream)
+		defer panic_recovery.Recover("spill_queue.Stage", panic_recovery.SendTo(done, queuedStream))
+		defer q.Sync()
+
+		sendErr := func(err error) {
+			select {
+			case <-done:
+			case queuedStream <- err:
+			}
+		}
+
+		for {
+			// a nil channel blocks forever, which disables the corresponding case
+			var outStream chan<- any
+			next, ok, err := q.Peek()
+			if err != nil {
+				sendErr(err)
+				return
+			}
+			if ok {
+				outStream = queuedStream
+			}
+
+			if valueStream == nil && outStream == nil {
+				return
+			}
+
+			select {
+			case <-done:
+				return
+			case v, ok := <-valueStream:
+				if !ok {
+					valueStream = nil
+					continue
+				}
+				err = q.Push(v)
+			case outStream <- next:
+				_, _, err = q.Pop()
+			}
+
+			if err != nil {
+				sendErr(err)
+				return
+			}
+		}
+	}()
+
+	return queuedStream
+}
+
+// SpillQueueExec produces values faster than they are consumed; the backlog is kept on disk
+func SpillQueueExec() {
+	dir, err := os.MkdirTemp("", "spill-queue")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := Open(dir, GobCodec{}, WithSegmentSize(64))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer q.Close()
+
+	done := make(chan any)
+	defer close(done)
+
+	queued := Stage(done, pipeline.Take(done, pipeline.Repeat(done, "I", "am."), 20), q)
+
+	for v := range queued {
+		fmt.Printf("%v (%d values left on disk)\n", v, q.Len())
+	}
+}
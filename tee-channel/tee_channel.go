@@ -2,6 +2,7 @@ package tee_channel
 
 import (
 	ordone "concurrency-patterns/or_done_channel"
+	"concurrency-patterns/panic_recovery"
 	"concurrency-patterns/pipeline"
 	"fmt"
 )
@@ -20,6 +21,7 @@ func Tee(
 	go func() {
 		defer close(out1)
 		defer close(out2)
+		defer panic_recovery.Recover("Tee", nil)
 
 		for val := range ordone.OrDone(done, in) {
 			var out1, out2 = out1, out2 // intentionally shadowed
@@ -0,0 +1,321 @@
+package work_stealing
+
+import (
+	"concurrency-patterns/panic_recovery"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// Work stealing
+//
+// fanning out hands the next value to whichever worker reads first from a shared channel. That's fine for uniform work,
+// but a task that spawns subtasks (fork/join) doesn't fit: the subtasks end up at the back of the shared queue, and the
+// worker that forked them sits idle while it waits to join them.
+//
+// in a work-stealing executor, every worker has its own deque:
+// 1. a worker pushes the tasks it forks to the tail of its own deque
+// 2. a worker pops the next task from the tail of its own deque - the task it forked last, whose data is most likely still in cache
+// 3. a worker that is waiting to join a task, works on other tasks in the meantime
+// 4. a worker whose deque is empty steals from the head of another worker's deque - the oldest task,
+//    which, in a recursive problem, tends to be the largest chunk of work
+//
+// Katherine: the work stealing algorithm follows a few basic rules. Given a thread of execution:
+// at a fork point, add tasks to the tail of the deque associated with the thread; if the thread is idle,
+// steal work from the head of deque associated with some other random thread; at a join point that cannot be realized yet,
+// pop work off the tail of the thread's own deque; if the thread's deque is empty, stall at a join or steal work.
+
+// ErrCanceled is returned for futures whose task did not run because the executor's done channel was closed
+var ErrCanceled = errors.New("work stealing: canceled")
+
+// Task is a unit of work; it can fork subtasks on the worker that runs it
+type Task func(w *Worker) (any, error)
+
+// Future is the result of a task that will be available once the task has run
+type Future struct {
+	ready chan struct{}
+	value any
+	err   error
+}
+
+func newFuture() *Future {
+	return &Future{ready: make(chan struct{})}
+}
+
+// Ready returns a channel that is closed once the task has run
+func (f *Future) Ready() <-chan struct{} {
+	return f.ready
+}
+
+// Get blocks until the task has run or done is closed; it is meant for callers outside the executor, tasks use Worker.Join
+//
+// it returns the task's error, or a panic in the task as *panic_recovery.PanicError.
+func (f *Future) Get(done <-chan any) (any, error) {
+	select {
+	case <-f.ready:
+		return f.value, f.err
+	case <-done:
+		return nil, ErrCanceled
+	}
+}
+
+type task struct {
+	fn     Task
+	future *Future
+}
+
+// Executor runs tasks on a fixed number of workers that steal work from each other
+type Executor struct {
+	done    <-chan any
+	workers []*Worker
+	inject  chan *task    // tasks submitted from outside the executor
+	wake    chan struct{} // signals idle workers that a task was forked
+	wg      sync.WaitGroup
+}
+
+// Worker runs tasks; it is passed to every task so the task can fork and join subtasks
+type Worker struct {
+	id int
+	ex *Executor
+
+	mu    sync.Mutex
+	deque []*task
+}
+
+// New starts an Executor with n workers that stop when done is closed
+func New(done <-chan any, n int) *Executor {
+	n = max(n, 1)
+	ex := &Executor{
+		done:   done,
+		inject: make(chan *task, 1024),
+		wake:   make(chan struct{}, n),
+	}
+
+	ex.workers = make([]*Worker, n)
+	for i := range ex.workers {
+		ex.workers[i] = &Worker{id: i, ex: ex}
+	}
+
+	ex.wg.Add(n)
+	for _, w := range ex.workers {
+		go w.loop()
+	}
+
+	return ex
+}
+
+// Submit queues fn to be run by one of the workers
+func (ex *Executor) Submit(fn Task) *Future {
+	t := &task{fn: fn, future: newFuture()}
+
+	// a select picks randomly among ready cases, so without the check a task submitted after done was closed could
+	// still be queued - where no worker would ever pick it up
+	select {
+	case <-ex.done:
+		t.future.err = ErrCanceled
+		close(t.future.ready)
+		return t.future
+	default:
+	}
+
+	select {
+	case <-ex.done:
+		t.future.err = ErrCanceled
+		close(t.future.ready)
+	case ex.inject <- t:
+	}
+
+	return t.future
+}
+
+// Wait blocks until all workers have stopped after done was closed
+func (ex *Executor) Wait() {
+	ex.wg.Wait()
+}
+
+// ID returns the index of the worker in the executor
+func (w *Worker) ID() int {
+	return w.id
+}
+
+// Fork pushes fn to the tail of the worker's deque, where it is either run by the worker itself or stolen by an idle one
+func (w *Worker) Fork(fn Task) *Future {
+	t := &task{fn: fn, future: newFuture()}
+
+	w.mu.Lock()
+	w.deque = append(w.deque, t)
+	w.mu.Unlock()
+
+	// wake up an idle worker, if there is one; if all of them are busy, they'll find the task when they look for work
+	select {
+	case w.ex.wake <- struct{}{}:
+	default:
+	}
+
+	return t.future
+}
+
+// Join waits for f, running other tasks in the meantime
+//
+// it returns the task's error - a panic in the task as *panic_recovery.PanicError -
+// or ErrCanceled if the executor was stopped before the task ran.
+func (w *Worker) Join(f *Future) (any, error) {
+	for {
+		select {
+		case <-f.ready:
+			return f.value, f.err
+		case <-w.ex.done:
+			return nil, ErrCanceled
+		default:
+		}
+
+		if t := w.findWork(false); t != nil {
+			w.run(t)
+			continue
+		}
+
+		// nothing to do: stall until the future is ready, or until there might be work to steal
+		select {
+		case <-f.ready:
+			return f.value, f.err
+		case <-w.ex.done:
+			return nil, ErrCanceled
+		case <-w.ex.wake:
+		}
+	}
+}
+
+// loop runs tasks until the executor is stopped
+func (w *Worker) loop() {
+	defer w.ex.wg.Done()
+	defer panic_recovery.Recover("work_stealing", nil)
+
+	for {
+		if t := w.findWork(true); t != nil {
+			w.run(t)
+			continue
+		}
+
+		select {
+		case <-w.ex.done:
+			return
+		case t := <-w.ex.inject:
+			w.run(t)
+		case <-w.ex.wake:
+		}
+	}
+}
+
+// findWork pops from the tail of the worker's own deque, or steals from the head of a random other worker's deque.
+// Tasks submitted from outside are only picked up by workers that don't wait for a join, if fromInject is set.
+func (w *Worker) findWork(fromInject bool) *task {
+	if t := w.popTail(); t != nil {
+		return t
+	}
+
+	workers := w.ex.workers
+	offset := rand.Intn(len(workers))
+	for i := range workers {
+		victim := workers[(offset+i)%len(workers)]
+		if victim == w {
+			continue
+		}
+		if t := victim.stealHead(); t != nil {
+			return t
+		}
+	}
+
+	if fromInject {
+		select {
+		case t := <-w.ex.inject:
+			return t
+		default:
+		}
+	}
+	return nil
+}
+
+func (w *Worker) popTail() *task {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.deque) == 0 {
+		return nil
+	}
+	t := w.deque[len(w.deque)-1]
+	w.deque = w.deque[:len(w.deque)-1]
+	return t
+}
+
+func (w *Worker) stealHead() *task {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.deque) == 0 {
+		return nil
+	}
+	t := w.deque[0]
+	w.deque = w.deque[1:]
+	return t
+}
+
+// run runs t and resolves its future; an error or a panic in the task is stored in the future
+func (w *Worker) run(t *task) {
+	defer close(t.future.ready)
+	defer panic_recovery.Recover("work_stealing", func(err *panic_recovery.PanicError) { t.future.err = err })
+
+	t.future.value, t.future.err = t.fn(w)
+}
+
+// Fib computes the n-th Fibonacci number recursively, forking one of the two recursive calls
+//
+// below a threshold, forking costs more than it saves, so small subproblems are computed sequentially.
+func Fib(n int) Task {
+	return func(w *Worker) (any, error) {
+		if n < 20 {
+			return fib(n), nil
+		}
+
+		f := w.Fork(Fib(n - 1))
+		b, err := Fib(n - 2)(w)
+		if err != nil {
+			return nil, err
+		}
+
+		a, err := w.Join(f)
+		if err != nil {
+			return nil, err
+		}
+		return a.(int) + b.(int), nil
+	}
+}
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+// WorkStealingExec computes a few Fibonacci numbers of very different cost on a work-stealing executor
+func WorkStealingExec() {
+	done := make(chan any)
+	defer close(done)
+
+	ex := New(done, 4)
+
+	futures := make([]*Future, 0, 4)
+	for _, n := range []int{10, 35, 20, 30} {
+		futures = append(futures, ex.Submit(Fib(n)))
+	}
+
+	for i, f := range futures {
+		v, err := f.Get(done)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			continue
+		}
+		fmt.Printf("fib #%d: %v\n", i, v)
+	}
+}
@@ -0,0 +1,247 @@
+package work_stealing_test
+
+import (
+	"concurrency-patterns/fan_out_fan_in"
+	"concurrency-patterns/panic_recovery"
+	"concurrency-patterns/pipeline"
+	"concurrency-patterns/work_stealing"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+const fibN = 30
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+// leaves splits fib(n) into subproblems whose results add up to fib(n); their costs differ a lot
+func leaves(n, depth int) []int {
+	if depth == 0 || n < 2 {
+		return []int{n}
+	}
+	return append(leaves(n-1, depth-1), leaves(n-2, depth-1)...)
+}
+
+func TestExecutor_Submit(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	ex := work_stealing.New(done, 4)
+
+	futures := make([]*work_stealing.Future, 100)
+	for i := range futures {
+		futures[i] = ex.Submit(func(*work_stealing.Worker) (any, error) { return i * i, nil })
+	}
+
+	for i, f := range futures {
+		if v, err := f.Get(done); err != nil || v != i*i {
+			t.Errorf("task %d: got %v, %v, want %d", i, v, err, i*i)
+		}
+	}
+}
+
+// sum adds up the integers in [from, to), forking the left half until the range is small
+func sum(from, to int) work_stealing.Task {
+	return func(w *work_stealing.Worker) (any, error) {
+		if to-from <= 4 {
+			s := 0
+			for i := from; i < to; i++ {
+				s += i
+			}
+			return s, nil
+		}
+
+		mid := (from + to) / 2
+		left := w.Fork(sum(from, mid))
+		right, err := sum(mid, to)(w)
+		if err != nil {
+			return nil, err
+		}
+
+		l, err := w.Join(left)
+		if err != nil {
+			return nil, err
+		}
+		return l.(int) + right.(int), nil
+	}
+}
+
+func TestExecutor_ForkJoin(t *testing.T) {
+	done := make(chan any)
+	defer close(done)
+
+	ex := work_stealing.New(done, 4)
+
+	if v, err := ex.Submit(sum(0, 10_000)).Get(done); err != nil || v != 49_995_000 {
+		t.Errorf("got %v, %v, want 49995000", v, err)
+	}
+	if v, err := ex.Submit(work_stealing.Fib(25)).Get(done); err != nil || v != fib(25) {
+		t.Errorf("got %v, %v, want %d", v, err, fib(25))
+	}
+}
+
+func TestExecutor_Panic(t *testing.T) {
+	panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+	defer panic_recovery.SetSupervisor(nil)
+
+	done := make(chan any)
+	defer close(done)
+
+	ex := work_stealing.New(done, 2)
+
+	var panicErr *panic_recovery.PanicError
+	if _, err := ex.Submit(func(*work_stealing.Worker) (any, error) { panic("boom") }).Get(done); !errors.As(err, &panicErr) {
+		t.Errorf("got %v, want the recovered panic", err)
+	}
+
+	// the worker survives the panicking task
+	if v, err := ex.Submit(func(*work_stealing.Worker) (any, error) { return 1, nil }).Get(done); err != nil || v != 1 {
+		t.Errorf("got %v, %v after the panic, want 1", v, err)
+	}
+}
+
+func TestWorker_JoinError(t *testing.T) {
+	panic_recovery.SetSupervisor(func(*panic_recovery.PanicError) {})
+	defer panic_recovery.SetSupervisor(nil)
+
+	done := make(chan any)
+	defer close(done)
+
+	ex := work_stealing.New(done, 2)
+
+	errBoom := errors.New("boom")
+	failing := func(*work_stealing.Worker) (any, error) { return nil, errBoom }
+	panicking := func(*work_stealing.Worker) (any, error) { panic("boom") }
+
+	// the error of a joined subtask is returned to the task that forked it, which passes it on
+	forkJoin := func(sub work_stealing.Task) work_stealing.Task {
+		return func(w *work_stealing.Worker) (any, error) {
+			return w.Join(w.Fork(sub))
+		}
+	}
+
+	if _, err := ex.Submit(forkJoin(failing)).Get(done); !errors.Is(err, errBoom) {
+		t.Errorf("got %v, want the error of the subtask", err)
+	}
+	var panicErr *panic_recovery.PanicError
+	if _, err := ex.Submit(forkJoin(panicking)).Get(done); !errors.As(err, &panicErr) {
+		t.Errorf("got %v, want the recovered panic of the subtask", err)
+	}
+}
+
+func TestWorker_JoinCanceled(t *testing.T) {
+	// a future of another executor whose only worker is busy, so it never becomes ready
+	other, release := make(chan any), make(chan any)
+	defer close(other)
+	defer close(release)
+
+	busy := work_stealing.New(other, 1)
+	busy.Submit(func(*work_stealing.Worker) (any, error) {
+		<-release
+		return nil, nil
+	})
+	pending := busy.Submit(func(*work_stealing.Worker) (any, error) { return 1, nil })
+
+	done := make(chan any)
+	ex := work_stealing.New(done, 1)
+
+	started, joined := make(chan any), make(chan error, 1)
+	ex.Submit(func(w *work_stealing.Worker) (any, error) {
+		close(started)
+		_, err := w.Join(pending)
+		joined <- err
+		return nil, err
+	})
+	<-started
+
+	close(done)
+	if err := <-joined; !errors.Is(err, work_stealing.ErrCanceled) {
+		t.Errorf("got %v, want ErrCanceled", err)
+	}
+	ex.Wait()
+}
+
+func TestExecutor_Canceled(t *testing.T) {
+	done := make(chan any)
+	ex := work_stealing.New(done, 1)
+
+	// the only worker is busy until the executor is stopped, so the second task never runs
+	started, release := make(chan any), make(chan any)
+	ex.Submit(func(*work_stealing.Worker) (any, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	<-started
+	f := ex.Submit(func(*work_stealing.Worker) (any, error) { return 1, nil })
+
+	close(done)
+	if _, err := f.Get(done); !errors.Is(err, work_stealing.ErrCanceled) {
+		t.Errorf("got %v, want ErrCanceled", err)
+	}
+
+	close(release)
+	ex.Wait()
+
+	if _, err := ex.Submit(func(*work_stealing.Worker) (any, error) { return 1, nil }).Get(make(chan any)); !errors.Is(err, work_stealing.ErrCanceled) {
+		t.Errorf("got %v for a task submitted after done was closed, want ErrCanceled", err)
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	b.Run("WorkStealing", func(b *testing.B) {
+		done := make(chan any)
+		defer close(done)
+
+		ex := work_stealing.New(done, runtime.NumCPU())
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if v, _ := ex.Submit(work_stealing.Fib(fibN)).Get(done); v != fib(fibN) {
+				b.Fatalf("wrong result %v", v)
+			}
+		}
+	})
+
+	// plain fan-out: the problem is split up front, and the subproblems are handed to whichever worker reads first
+	b.Run("FanIn", func(b *testing.B) {
+		subproblems := leaves(fibN, 6)
+
+		for i := 0; i < b.N; i++ {
+			done := make(chan any)
+
+			subproblemStream := pipeline.Generator(done, subproblems...)
+			workers := make([]<-chan any, runtime.NumCPU())
+			for w := range workers {
+				resultStream := make(chan any)
+				workers[w] = resultStream
+
+				go func() {
+					defer close(resultStream)
+					for n := range subproblemStream {
+						select {
+						case <-done:
+							return
+						case resultStream <- fib(n):
+						}
+					}
+				}()
+			}
+
+			sum := 0
+			for v := range fan_out_fan_in.FanIn(done, workers...) {
+				sum += v.(int)
+			}
+			close(done)
+
+			if sum != fib(fibN) {
+				b.Fatalf("wrong result %v", sum)
+			}
+		}
+	})
+}